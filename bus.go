@@ -0,0 +1,410 @@
+package dbus
+
+import (
+	"container/vector"
+	"os"
+)
+
+// Flags for RequestName.
+type RequestNameFlags uint32
+
+const (
+	NAME_FLAG_ALLOW_REPLACEMENT = 0x1
+	NAME_FLAG_REPLACE_EXISTING  = 0x2
+	NAME_FLAG_DO_NOT_QUEUE      = 0x4
+)
+
+// Results of RequestName.
+type RequestNameReply uint32
+
+const (
+	REQUEST_NAME_REPLY_PRIMARY_OWNER = 1
+	REQUEST_NAME_REPLY_IN_QUEUE      = 2
+	REQUEST_NAME_REPLY_EXISTS        = 3
+	REQUEST_NAME_REPLY_ALREADY_OWNER = 4
+)
+
+// Results of ReleaseName.
+type ReleaseNameReply uint32
+
+const (
+	RELEASE_NAME_REPLY_RELEASED     = 1
+	RELEASE_NAME_REPLY_NON_EXISTENT = 2
+	RELEASE_NAME_REPLY_NOT_OWNER    = 3
+)
+
+// RequestName asks the bus to assign name to the connection. Unless
+// NAME_FLAG_DO_NOT_QUEUE is given, a name that is already owned puts the
+// connection in the queue for it; use WatchNameOwnership to learn when it
+// becomes the owner. Requested names are requested again after a
+// reconnect.
+func (p *Connection) RequestName(name string, flags RequestNameFlags) (RequestNameReply, os.Error) {
+	return p.RequestNameCancel(nil, 0, name, flags)
+}
+
+// RequestNameCancel is like RequestName, but bounded by cancel and
+// timeout as with CallMethodCancel.
+func (p *Connection) RequestNameCancel(cancel <-chan bool, timeout int64, name string, flags RequestNameFlags) (RequestNameReply, os.Error) {
+	ret, e := p.CallMethodCancel(cancel, timeout, p.proxy, "RequestName", name, uint32(flags))
+	if e != nil {
+		return 0, e
+	}
+	if len(ret) == 0 {
+		return 0, os.NewError("Invalid RequestName reply")
+	}
+	reply, ok := ret[0].(uint32)
+	if !ok {
+		return 0, os.NewError("Invalid RequestName reply")
+	}
+
+	p.replyMutex.Lock()
+	defer p.replyMutex.Unlock()
+
+	switch reply {
+	case REQUEST_NAME_REPLY_PRIMARY_OWNER, REQUEST_NAME_REPLY_ALREADY_OWNER:
+		p.names[name] = true
+		p.requested[name] = flags
+	case REQUEST_NAME_REPLY_IN_QUEUE:
+		p.requested[name] = flags
+	}
+	return RequestNameReply(reply), nil
+}
+
+// ReleaseName gives up ownership of name, or leaves its queue.
+func (p *Connection) ReleaseName(name string) (ReleaseNameReply, os.Error) {
+	return p.ReleaseNameCancel(nil, 0, name)
+}
+
+// ReleaseNameCancel is like ReleaseName, but bounded by cancel and
+// timeout as with CallMethodCancel.
+func (p *Connection) ReleaseNameCancel(cancel <-chan bool, timeout int64, name string) (ReleaseNameReply, os.Error) {
+	p.replyMutex.Lock()
+	p.requested[name] = 0, false
+	p.replyMutex.Unlock()
+
+	ret, e := p.CallMethodCancel(cancel, timeout, p.proxy, "ReleaseName", name)
+	if e != nil {
+		return 0, e
+	}
+	if len(ret) == 0 {
+		return 0, os.NewError("Invalid ReleaseName reply")
+	}
+	reply, ok := ret[0].(uint32)
+	if !ok {
+		return 0, os.NewError("Invalid ReleaseName reply")
+	}
+
+	p.replyMutex.Lock()
+	p.names[name] = false, false
+	p.replyMutex.Unlock()
+	return ReleaseNameReply(reply), nil
+}
+
+// WatchNameOwnership calls proc with true when the connection becomes the
+// owner of name, for example after waiting in its queue, and with false
+// when it loses it. proc runs on the run loop, as with AddSignalHandler.
+func (p *Connection) WatchNameOwnership(name string, proc func(owned bool)) (*SignalHandle, os.Error) {
+	mr := &MatchRule{
+		Type:      "signal",
+		Sender:    "org.freedesktop.DBus",
+		Interface: "org.freedesktop.DBus",
+		Args:      map[int]string{0: name},
+	}
+	return p.AddSignalHandler(mr, func(msg *Message) {
+		if !p._IsForUs(msg) {
+			return
+		}
+		switch msg.Member {
+		case "NameAcquired":
+			proc(true)
+		case "NameLost":
+			proc(false)
+		}
+	})
+}
+
+// _TrackNames follows the NameAcquired and NameLost signals the bus sends
+// us, so that calls to our well-known names are answered.
+func (p *Connection) _TrackNames(msg *Message) {
+	if msg.Sender != "org.freedesktop.DBus" || msg.Iface != "org.freedesktop.DBus" || !p._IsForUs(msg) {
+		return
+	}
+	name, ok := _StringArg(msg, 0)
+	if !ok {
+		return
+	}
+
+	p.replyMutex.Lock()
+	defer p.replyMutex.Unlock()
+
+	switch msg.Member {
+	case "NameAcquired":
+		p.names[name] = true
+	case "NameLost":
+		p.names[name] = false, false
+	}
+}
+
+// _RequestedNames returns the names to request again after a reconnect,
+// and forgets the names owned on the old connection.
+func (p *Connection) _RequestedNames() map[string]RequestNameFlags {
+	p.replyMutex.Lock()
+	defer p.replyMutex.Unlock()
+
+	p.names = make(map[string]bool)
+	requested := make(map[string]RequestNameFlags)
+	for name, flags := range p.requested {
+		requested[name] = flags
+	}
+	return requested
+}
+
+// Results of StartServiceByName.
+type StartServiceReply uint32
+
+const (
+	START_REPLY_SUCCESS         = 1
+	START_REPLY_ALREADY_RUNNING = 2
+)
+
+// _CallBus calls a method of the bus and returns its first result. cancel
+// and timeout bound the call as with CallMethodCancel.
+func (p *Connection) _CallBus(cancel <-chan bool, timeout int64, name string, args ...) (interface{}, os.Error) {
+	ret, e := p.CallMethodCancel(cancel, timeout, p.proxy, name, args)
+	if e != nil {
+		return nil, e
+	}
+	if len(ret) == 0 {
+		return nil, os.NewError("Invalid " + name + " reply")
+	}
+	return ret[0], nil
+}
+
+func (p *Connection) _CallBusString(cancel <-chan bool, timeout int64, name string, args ...) (string, os.Error) {
+	ret, e := p._CallBus(cancel, timeout, name, args)
+	if e != nil {
+		return "", e
+	}
+	str, ok := ret.(string)
+	if !ok {
+		return "", os.NewError("Invalid " + name + " reply")
+	}
+	return str, nil
+}
+
+func (p *Connection) _CallBusUint32(cancel <-chan bool, timeout int64, name string, args ...) (uint32, os.Error) {
+	ret, e := p._CallBus(cancel, timeout, name, args)
+	if e != nil {
+		return 0, e
+	}
+	u, ok := ret.(uint32)
+	if !ok {
+		return 0, os.NewError("Invalid " + name + " reply")
+	}
+	return u, nil
+}
+
+// _IsArray reports whether the reply value is an array at all; an empty
+// array and a value of another type both have no members.
+func _IsArray(val interface{}) bool {
+	switch val.(type) {
+	case *vector.Vector, []interface{}:
+		return true
+	}
+	return false
+}
+
+func (p *Connection) _CallBusStrings(cancel <-chan bool, timeout int64, name string, args ...) ([]string, os.Error) {
+	ret, e := p._CallBus(cancel, timeout, name, args)
+	if e != nil {
+		return nil, e
+	}
+	if !_IsArray(ret) {
+		return nil, os.NewError("Invalid " + name + " reply")
+	}
+	elems := _ToSlice(ret)
+	strs := make([]string, len(elems))
+	for i, v := range elems {
+		str, ok := v.(string)
+		if !ok {
+			return nil, os.NewError("Invalid " + name + " reply")
+		}
+		strs[i] = str
+	}
+	return strs, nil
+}
+
+func (p *Connection) _CallBusBytes(cancel <-chan bool, timeout int64, name string, args ...) ([]byte, os.Error) {
+	ret, e := p._CallBus(cancel, timeout, name, args)
+	if e != nil {
+		return nil, e
+	}
+	if !_IsArray(ret) {
+		return nil, os.NewError("Invalid " + name + " reply")
+	}
+	elems := _ToSlice(ret)
+	b := make([]byte, len(elems))
+	for i, v := range elems {
+		c, ok := v.(byte)
+		if !ok {
+			return nil, os.NewError("Invalid " + name + " reply")
+		}
+		b[i] = c
+	}
+	return b, nil
+}
+
+// Every bus method below has a Cancel variant taking the cancel channel
+// and timeout of CallMethodCancel.
+
+// ListNames returns the names currently owned on the bus.
+func (p *Connection) ListNames() ([]string, os.Error) {
+	return p.ListNamesCancel(nil, 0)
+}
+
+func (p *Connection) ListNamesCancel(cancel <-chan bool, timeout int64) ([]string, os.Error) {
+	return p._CallBusStrings(cancel, timeout, "ListNames")
+}
+
+// ListActivatableNames returns the names the bus can start services for.
+func (p *Connection) ListActivatableNames() ([]string, os.Error) {
+	return p.ListActivatableNamesCancel(nil, 0)
+}
+
+func (p *Connection) ListActivatableNamesCancel(cancel <-chan bool, timeout int64) ([]string, os.Error) {
+	return p._CallBusStrings(cancel, timeout, "ListActivatableNames")
+}
+
+// NameHasOwner reports whether name is currently owned.
+func (p *Connection) NameHasOwner(name string) (bool, os.Error) {
+	return p.NameHasOwnerCancel(nil, 0, name)
+}
+
+func (p *Connection) NameHasOwnerCancel(cancel <-chan bool, timeout int64, name string) (bool, os.Error) {
+	ret, e := p._CallBus(cancel, timeout, "NameHasOwner", name)
+	if e != nil {
+		return false, e
+	}
+	b, ok := ret.(bool)
+	if !ok {
+		return false, os.NewError("Invalid NameHasOwner reply")
+	}
+	return b, nil
+}
+
+// GetNameOwner returns the unique name of the owner of name.
+func (p *Connection) GetNameOwner(name string) (string, os.Error) {
+	return p.GetNameOwnerCancel(nil, 0, name)
+}
+
+func (p *Connection) GetNameOwnerCancel(cancel <-chan bool, timeout int64, name string) (string, os.Error) {
+	return p._CallBusString(cancel, timeout, "GetNameOwner", name)
+}
+
+// ListQueuedOwners returns the unique names waiting for name, the
+// current owner first.
+func (p *Connection) ListQueuedOwners(name string) ([]string, os.Error) {
+	return p.ListQueuedOwnersCancel(nil, 0, name)
+}
+
+func (p *Connection) ListQueuedOwnersCancel(cancel <-chan bool, timeout int64, name string) ([]string, os.Error) {
+	return p._CallBusStrings(cancel, timeout, "ListQueuedOwners", name)
+}
+
+// GetConnectionUnixUser returns the Unix user id of the connection owning
+// name.
+func (p *Connection) GetConnectionUnixUser(name string) (uint32, os.Error) {
+	return p.GetConnectionUnixUserCancel(nil, 0, name)
+}
+
+func (p *Connection) GetConnectionUnixUserCancel(cancel <-chan bool, timeout int64, name string) (uint32, os.Error) {
+	return p._CallBusUint32(cancel, timeout, "GetConnectionUnixUser", name)
+}
+
+// GetConnectionUnixProcessID returns the process id of the connection
+// owning name.
+func (p *Connection) GetConnectionUnixProcessID(name string) (uint32, os.Error) {
+	return p.GetConnectionUnixProcessIDCancel(nil, 0, name)
+}
+
+func (p *Connection) GetConnectionUnixProcessIDCancel(cancel <-chan bool, timeout int64, name string) (uint32, os.Error) {
+	return p._CallBusUint32(cancel, timeout, "GetConnectionUnixProcessID", name)
+}
+
+// GetConnectionSELinuxSecurityContext returns the SELinux context of the
+// connection owning name.
+func (p *Connection) GetConnectionSELinuxSecurityContext(name string) ([]byte, os.Error) {
+	return p.GetConnectionSELinuxSecurityContextCancel(nil, 0, name)
+}
+
+func (p *Connection) GetConnectionSELinuxSecurityContextCancel(cancel <-chan bool, timeout int64, name string) ([]byte, os.Error) {
+	return p._CallBusBytes(cancel, timeout, "GetConnectionSELinuxSecurityContext", name)
+}
+
+// GetConnectionCredentials returns everything the bus knows about the
+// connection owning name, such as "UnixUserID" and "ProcessID".
+func (p *Connection) GetConnectionCredentials(name string) (map[string]interface{}, os.Error) {
+	return p.GetConnectionCredentialsCancel(nil, 0, name)
+}
+
+func (p *Connection) GetConnectionCredentialsCancel(cancel <-chan bool, timeout int64, name string) (map[string]interface{}, os.Error) {
+	ret, e := p._CallBus(cancel, timeout, "GetConnectionCredentials", name)
+	if e != nil {
+		return nil, e
+	}
+	if !_IsArray(ret) {
+		return nil, os.NewError("Invalid GetConnectionCredentials reply")
+	}
+	return _PropertyMap(ret), nil
+}
+
+// GetAdtAuditSessionData returns the Solaris audit session data of the
+// connection owning name.
+func (p *Connection) GetAdtAuditSessionData(name string) ([]byte, os.Error) {
+	return p.GetAdtAuditSessionDataCancel(nil, 0, name)
+}
+
+func (p *Connection) GetAdtAuditSessionDataCancel(cancel <-chan bool, timeout int64, name string) ([]byte, os.Error) {
+	return p._CallBusBytes(cancel, timeout, "GetAdtAuditSessionData", name)
+}
+
+// StartServiceByName asks the bus to start the service for name. flags
+// is currently unused and should be 0.
+func (p *Connection) StartServiceByName(name string, flags uint32) (StartServiceReply, os.Error) {
+	return p.StartServiceByNameCancel(nil, 0, name, flags)
+}
+
+func (p *Connection) StartServiceByNameCancel(cancel <-chan bool, timeout int64, name string, flags uint32) (StartServiceReply, os.Error) {
+	reply, e := p._CallBusUint32(cancel, timeout, "StartServiceByName", name, flags)
+	return StartServiceReply(reply), e
+}
+
+// GetId returns the unique id of the bus.
+func (p *Connection) GetId() (string, os.Error) {
+	return p.GetIdCancel(nil, 0)
+}
+
+func (p *Connection) GetIdCancel(cancel <-chan bool, timeout int64) (string, os.Error) {
+	return p._CallBusString(cancel, timeout, "GetId")
+}
+
+// UpdateActivationEnvironment adds env to the environment of services
+// the bus starts.
+func (p *Connection) UpdateActivationEnvironment(env map[string]string) os.Error {
+	return p.UpdateActivationEnvironmentCancel(nil, 0, env)
+}
+
+func (p *Connection) UpdateActivationEnvironmentCancel(cancel <-chan bool, timeout int64, env map[string]string) os.Error {
+	_, e := p.CallMethodCancel(cancel, timeout, p.proxy, "UpdateActivationEnvironment", env)
+	return e
+}
+
+// ReloadConfig makes the bus reload its configuration.
+func (p *Connection) ReloadConfig() os.Error {
+	return p.ReloadConfigCancel(nil, 0)
+}
+
+func (p *Connection) ReloadConfigCancel(cancel <-chan bool, timeout int64) os.Error {
+	_, e := p.CallMethodCancel(cancel, timeout, p.proxy, "ReloadConfig")
+	return e
+}
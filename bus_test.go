@@ -0,0 +1,135 @@
+package dbus
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestRequestName(t *testing.T) {
+	con, _ := newTestConnection()
+	con._SetUniqName(":1.1")
+
+	msg := &Message{Dest: "org.test.Name"}
+	if con._IsForUs(msg) {
+		t.Error("#1 Failed")
+	}
+
+	reply, e := con.RequestName("org.test.Name", NAME_FLAG_DO_NOT_QUEUE)
+	if e != nil || REQUEST_NAME_REPLY_PRIMARY_OWNER != reply {
+		t.Error("#2 Failed:", reply, e)
+	}
+	if !con._IsForUs(msg) {
+		t.Error("#3 Failed")
+	}
+
+	released, e := con.ReleaseName("org.test.Name")
+	if e != nil || RELEASE_NAME_REPLY_RELEASED != released {
+		t.Error("#4 Failed:", released, e)
+	}
+	if con._IsForUs(msg) {
+		t.Error("#5 Failed")
+	}
+}
+
+func TestWatchNameOwnership(t *testing.T) {
+	con, bus := newTestConnection()
+	con._SetUniqName(":1.1")
+
+	owned := make(chan bool, 2)
+	if _, e := con.WatchNameOwnership("org.queued.Name", func(b bool) { owned <- b }); e != nil {
+		t.Fatal(e)
+	}
+
+	reply, e := con.RequestName("org.queued.Name", 0)
+	if e != nil || REQUEST_NAME_REPLY_IN_QUEUE != reply {
+		t.Error("#1 Failed:", reply, e)
+	}
+
+	bus._EmitNameSignal("NameAcquired", "org.queued.Name")
+	if !<-owned {
+		t.Error("#2 Failed")
+	}
+	if !con._IsForUs(&Message{Dest: "org.queued.Name"}) {
+		t.Error("#3 Failed")
+	}
+
+	bus._EmitNameSignal("NameLost", "org.queued.Name")
+	if <-owned {
+		t.Error("#4 Failed")
+	}
+}
+
+func TestReconnectRequestsNames(t *testing.T) {
+	con, bus := newTestConnection()
+
+	buses := make(chan *testBus, 1)
+	con.dial = func() (net.Conn, os.Error) {
+		newBus, conn := newTestBus()
+		go func() { newBus._Serve(newBus._ServeAuth()) }()
+		buses <- newBus
+		return conn, nil
+	}
+	done := make(chan bool, 1)
+	con.SetReconnectPolicy(&ReconnectPolicy{
+		InitialDelay: 1e6,
+		OnReconnect:  func(name string, e os.Error) { done <- e == nil },
+	})
+
+	con.RequestName("org.test.Kept", 0)
+	con.RequestName("org.test.Released", 0)
+	con.ReleaseName("org.test.Released")
+
+	bus.conn.Close()
+	if !<-done {
+		t.Error("#1 Failed")
+	}
+
+	newBus := <-buses
+	newBus.mutex.Lock()
+	if 1 != newBus.names.Len() || "org.test.Kept" != newBus.names.At(0) {
+		t.Error("#2 Failed:", newBus.names.Data())
+	}
+	newBus.mutex.Unlock()
+}
+
+func TestBusMethods(t *testing.T) {
+	con, _ := newTestConnection()
+
+	if names, e := con.ListNames(); e != nil || 1 != len(names) || "org.freedesktop.DBus" != names[0] {
+		t.Error("#1 Failed:", names, e)
+	}
+	if b, e := con.NameHasOwner("org.freedesktop.DBus"); e != nil || !b {
+		t.Error("#2 Failed:", b, e)
+	}
+	if b, e := con.NameHasOwner("org.test.Missing"); e != nil || b {
+		t.Error("#3 Failed:", b, e)
+	}
+	if owner, e := con.GetNameOwner("org.freedesktop.DBus"); e != nil || "org.freedesktop.DBus" != owner {
+		t.Error("#4 Failed:", owner, e)
+	}
+	if id, e := con.GetId(); e != nil || 32 != len(id) {
+		t.Error("#5 Failed:", id, e)
+	}
+	if uid, e := con.GetConnectionUnixUser(":1.1"); e != nil || 1000 != uid {
+		t.Error("#6 Failed:", uid, e)
+	}
+	if creds, e := con.GetConnectionCredentials(":1.1"); e != nil || uint32(1000) != creds["UnixUserID"].(uint32) {
+		t.Error("#7 Failed:", creds, e)
+	}
+	if _, e := con.GetAdtAuditSessionData(":1.1"); e == nil {
+		t.Error("#8 Failed")
+	}
+	if owners, e := con.ListQueuedOwners("org.freedesktop.DBus"); e != nil || 1 != len(owners) || "org.freedesktop.DBus" != owners[0] {
+		t.Error("#9 Failed:", owners, e)
+	}
+	if e := con.UpdateActivationEnvironment(map[string]string{"A": "B"}); e != nil {
+		t.Error("#10 Failed:", e)
+	}
+	if _, e := con.ListActivatableNames(); e == nil || "Invalid ListActivatableNames reply" != e.String() {
+		t.Error("#11 Failed:", e)
+	}
+	if _, e := con.GetConnectionCredentials(":1.2"); e == nil || "Invalid GetConnectionCredentials reply" != e.String() {
+		t.Error("#12 Failed:", e)
+	}
+}
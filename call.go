@@ -0,0 +1,99 @@
+package dbus
+
+import (
+	"os"
+)
+
+// An Error is an ERROR message received in reply to a method call.
+type Error struct {
+	Name string
+	Body []interface{}
+}
+
+func (p *Error) String() string {
+	if 0 < len(p.Body) {
+		if str, ok := p.Body[0].(string); ok {
+			return p.Name + ": " + str
+		}
+	}
+	return p.Name
+}
+
+// A Call represents a pending or completed method call. When the reply
+// arrives, Body or Err is filled in and the Call is sent on Done.
+type Call struct {
+	Method string
+	Args   []interface{}
+	Body   []interface{}
+	Err    os.Error
+	Done   chan *Call
+}
+
+func (p *Call) _Finish() {
+	select {
+	case p.Done <- p:
+	default:
+		// never block the run loop; a full Done channel is the caller's bug
+	}
+}
+
+func (p *Call) _SetReply(reply *Message) {
+	if reply.Type == ERROR {
+		p.Err = &Error{reply.ErrorName, reply.Params.Data()}
+		return
+	}
+	p.Body = reply.Params.Data()
+}
+
+// Go invokes the method asynchronously. It returns the Call structure
+// representing the invocation; the same Call is sent on done when the
+// reply arrives. If done is nil, Go allocates a new channel. If non-nil,
+// done must be buffered or Go will deliberately crash.
+func (p *Connection) Go(iface *Interface, name string, done chan *Call, args ...) *Call {
+	return p.GoCancel(nil, 0, iface, name, done, args)
+}
+
+// GoCancel is like Go, but the Call fails with a NoReply error after
+// timeout nanoseconds, or as soon as cancel becomes readable. The pending
+// reply is forgotten in either case. timeout is interpreted as by
+// CallMethodCancel.
+func (p *Connection) GoCancel(cancel <-chan bool, timeout int64, iface *Interface, name string, done chan *Call, args ...) *Call {
+	call := new(Call)
+	call.Method = name
+	call.Args = _ArgToVector(args).Data()
+
+	if done == nil {
+		done = make(chan *Call, 10)
+	} else if cap(done) == 0 {
+		panic("dbus: done channel is unbuffered")
+	}
+	call.Done = done
+
+	method := iface.intro.GetMethodData(name)
+	if nil == method {
+		call.Err = os.NewError("Invalid Method")
+		call._Finish()
+		return call
+	}
+
+	msg := NewMessage()
+
+	msg.Type = METHOD_CALL
+	msg.Path = iface.obj.path
+	msg.Iface = iface.name
+	msg.Dest = iface.obj.dest
+	msg.Member = name
+	msg.Sig = method.GetInSignature()
+	msg.Params.AppendVector(_ArgToVector(args))
+
+	e := p._SendAsyncCancel(msg, timeout, cancel, func(reply *Message) {
+		call._SetReply(reply)
+		call._Finish()
+	})
+	if e != nil {
+		call.Err = e
+		call._Finish()
+	}
+
+	return call
+}
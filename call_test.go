@@ -0,0 +1,124 @@
+package dbus
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestErrorString(t *testing.T) {
+	e := &Error{"org.freedesktop.DBus.Error.Failed", []interface{}{"failed"}}
+	if "org.freedesktop.DBus.Error.Failed: failed" != e.String() {
+		t.Error("#1 Failed:", e.String())
+	}
+	e = &Error{"org.freedesktop.DBus.Error.Failed", nil}
+	if "org.freedesktop.DBus.Error.Failed" != e.String() {
+		t.Error("#2 Failed:", e.String())
+	}
+}
+
+func TestGoInvalidMethod(t *testing.T) {
+	con := new(Connection)
+	proxy := con._GetProxy()
+
+	call := con.Go(proxy, "Hoo", nil) // unknown method name
+	if call != <-call.Done {
+		t.Error("#1 Failed")
+	}
+	if call.Err == nil {
+		t.Error("#2 Failed")
+	}
+}
+
+// discardConn swallows everything written to it and never replies.
+type discardConn struct{}
+
+func (p discardConn) Read(b []byte) (int, os.Error) { select {} }
+func (p discardConn) Write(b []byte) (int, os.Error) { return len(b), nil }
+func (p discardConn) Close() os.Error { return nil }
+func (p discardConn) LocalAddr() net.Addr { return nil }
+func (p discardConn) RemoteAddr() net.Addr { return nil }
+func (p discardConn) SetTimeout(nsec int64) os.Error { return nil }
+func (p discardConn) SetReadTimeout(nsec int64) os.Error { return nil }
+func (p discardConn) SetWriteTimeout(nsec int64) os.Error { return nil }
+
+func newDiscardConnection() *Connection {
+	con := new(Connection)
+	con.conn = discardConn{}
+	con._Init()
+	return con
+}
+
+func TestCallTimeout(t *testing.T) {
+	con := newDiscardConnection()
+
+	_, e := con.CallMethodCancel(nil, 1e6, con.proxy, "ListNames")
+	if e == nil {
+		t.Fatal("#1 Failed")
+	}
+	if err, ok := e.(*Error); !ok || errorNoReply != err.Name {
+		t.Error("#2 Failed:", e)
+	}
+	if 0 != len(con.methodCallReplies) {
+		t.Error("#3 Failed")
+	}
+}
+
+func TestCallCancel(t *testing.T) {
+	con := newDiscardConnection()
+
+	cancel := make(chan bool)
+	call := con.GoCancel(cancel, 0, con.proxy, "ListNames", nil)
+	close(cancel)
+	<-call.Done
+
+	if err, ok := call.Err.(*Error); !ok || errorNoReply != err.Name {
+		t.Error("#1 Failed:", call.Err)
+	}
+	if 0 != len(con.methodCallReplies) {
+		t.Error("#2 Failed")
+	}
+}
+
+func TestCallTimeoutValues(t *testing.T) {
+	con := newDiscardConnection()
+
+	// a negative timeout outlasts a short connection default
+	con.SetTimeout(1e6)
+	cancel := make(chan bool)
+	call := con.GoCancel(cancel, -1, con.proxy, "ListNames", nil)
+	time.Sleep(2e7)
+	close(cancel)
+	<-call.Done
+	if err, ok := call.Err.(*Error); !ok || "Method call was cancelled" != err.Body[0] {
+		t.Error("#1 Failed:", call.Err)
+	}
+
+	con.SetTimeout(0)
+	if timeout := con._Timeout(); DefaultTimeout != timeout {
+		t.Error("#2 Failed:", timeout)
+	}
+}
+
+func TestCallVariantsCancel(t *testing.T) {
+	con := newDiscardConnection()
+
+	cancel := make(chan bool)
+	close(cancel)
+	if _, e := con.ListNamesCancel(cancel, 0); e == nil {
+		t.Error("#1 Failed")
+	}
+	if _, e := con.RequestNameCancel(cancel, 0, "org.test.Name", 0); e == nil {
+		t.Error("#2 Failed")
+	}
+	if _, e := con.PingCancel(nil, 1e6, ""); e == nil {
+		t.Error("#3 Failed")
+	}
+	if obj, e := con.GetObjectCancel(nil, 1e6, "org.test", "/org/test"); e == nil || obj == nil || obj.GetIntrospect() != nil {
+		t.Error("#4 Failed:", e)
+	}
+	if 0 != len(con.methodCallReplies) {
+		t.Error("#5 Failed")
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"net"
 	"regexp"
 	"os"
-	"fmt"
 	"container/vector"
 //	"strings"
 	"bytes"
 	"reflect"
+	"sync"
+	"time"
 )
 
 const dbusXMLIntro = `
@@ -76,6 +77,20 @@ const dbusXMLIntro = `
     </method>
     <method name="ReloadConfig">
     </method>
+    <method name="GetConnectionCredentials">
+      <arg direction="in" type="s"/>
+      <arg direction="out" type="a{sv}"/>
+    </method>
+    <method name="GetId">
+      <arg direction="out" type="s"/>
+    </method>
+    <method name="UpdateActivationEnvironment">
+      <arg direction="in" type="a{ss}"/>
+    </method>
+    <method name="GetAdtAuditSessionData">
+      <arg direction="in" type="s"/>
+      <arg direction="out" type="ay"/>
+    </method>
     <signal name="NameOwnerChanged">
       <arg type="s"/>
       <arg type="s"/>
@@ -90,9 +105,31 @@ const dbusXMLIntro = `
   </interface>
 </node>`
 
+// DefaultTimeout is the time in nanoseconds a method call waits for its
+// reply, matching libdbus.
+const DefaultTimeout = 25e9
+
+const (
+	errorNoReply      = "org.freedesktop.DBus.Error.NoReply"
+	errorDisconnected = "org.freedesktop.DBus.Error.Disconnected"
+)
+
+var ErrClosed = os.NewError("ConnectionClosed")
+
 type signalHandler struct{
 	mr MatchRule
-	proc func(*Message)
+	proc func(*Message)  // called from the run loop, or
+	ch   chan<- *Message // sent to without blocking
+	anyType bool         // receives every message type, not only signals
+	owner *NameWatch     // follows the owner of a well-known sender
+}
+
+// _Owner returns the unique name owning the sender of the rule, if any.
+func (p *signalHandler) _Owner() string {
+	if p.owner == nil {
+		return ""
+	}
+	return p.owner.Owner()
 }
 
 type Connection struct {
@@ -104,9 +141,33 @@ type Connection struct {
 	conn              net.Conn
 	buffer            *bytes.Buffer
 	proxy             *Interface
+	dial              func() (net.Conn, os.Error)
+	reconnect         *ReconnectPolicy
+	capture           *PcapWriter
+	timeout           int64
+	replyMutex        sync.Mutex   // guards methodCallReplies, uniqName, timeout, reconnect, names, requested, isClosed and err
+	names             map[string]bool
+	requested         map[string]RequestNameFlags
+	isClosed          bool
+	err               os.Error
+	closed            chan bool
+	handlerMutex      sync.RWMutex // guards signalMatchRules and dropped
+	dropped           int64
+	matchMutex        sync.Mutex // guards matchRefs and matchPending and orders AddMatch/RemoveMatch
+	matchRefs         map[string]int
+	matchPending      map[string]*pendingMatch
+	writeMutex        sync.Mutex   // serializes writes to conn
+	captureMutex      sync.Mutex   // guards capture
+	exportMutex       sync.RWMutex // guards exports and managers
+	exports           map[string]map[string]*exportedInterface
+	managers          map[string]bool
+	callMutex         sync.Mutex // guards calls
+	calls             *vector.Vector
+	callReady         chan bool
 }
 
 type Object struct {
+	conn  *Connection
 	dest  string
 	path  string
 	intro Introspect
@@ -121,20 +182,29 @@ type Interface struct {
 func NewSessionBus() (*Connection, os.Error){
 	bus := new(Connection)
 	bus.path = os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	bus.dial = func() (net.Conn, os.Error) { return _DialSessionBus(bus.path) }
 
+	conn, err := bus.dial()
+	if err != nil{
+		return nil, err
+	}
+	bus.conn = conn
+	return bus,nil
+}
+
+func _DialSessionBus(path string) (net.Conn, os.Error){
 	var re *regexp.Regexp
 	re, _ = regexp.Compile("^unix:abstract=(.*),guid=(.*)")
 
-	m := re.ExecuteString(bus.path)
+	m := re.ExecuteString(path)
 	if nil != m {
-		abPath := bus.path[m[2]:m[3]] // get regexp 1st group
+		abPath := path[m[2]:m[3]] // get regexp 1st group
 		addr, _ := net.ResolveUnixAddr("unix", "\x00"+abPath)
 		conn, err := net.DialUnix("unix", nil, addr)
 		if err != nil{
 			return nil, err
 		}
-		bus.conn = conn
-		return bus,nil
+		return conn,nil
 	}
 
 	return nil, os.NewError("NewSessionBus Failed")
@@ -143,9 +213,9 @@ func NewSessionBus() (*Connection, os.Error){
 func NewSystemBus() (*Connection, os.Error){
 	bus := new(Connection)
 	bus.path = "unix:path=/var/run/dbus/system_bus_socket"
+	bus.dial = _DialSystemBus
 
-	addr, _ := net.ResolveUnixAddr("unix", "/var/run/dbus/system_bus_socket")
-	conn, err := net.DialUnix("unix", nil, addr)
+	conn, err := bus.dial()
 	if err != nil{
 		return nil, err
 	}
@@ -153,69 +223,259 @@ func NewSystemBus() (*Connection, os.Error){
 	return bus,nil
 }
 
+func _DialSystemBus() (net.Conn, os.Error){
+	addr, _ := net.ResolveUnixAddr("unix", "/var/run/dbus/system_bus_socket")
+	conn, err := net.DialUnix("unix", nil, addr)
+	if err != nil{
+		return nil, err
+	}
+	return conn,nil
+}
+
+// Initialize authenticates, starts the run loop and registers with the
+// bus by sending Hello. If either step fails the connection is closed
+// and the error returned.
 func (p *Connection) Initialize() os.Error {
+	p._Init()
+	if e := p._Auth(p.conn); e != nil {
+		p.Close()
+		return e
+	}
+	go p._RunLoop()
+	if e := p._SendHello(); e != nil {
+		p.Close()
+		return e
+	}
+	return nil
+}
+
+func (p *Connection) _Init() {
 	p.methodCallReplies = make(map[uint32]func(*Message))
 	p.signalMatchRules = new(vector.Vector)
+	p.matchRefs = make(map[string]int)
+	p.matchPending = make(map[string]*pendingMatch)
+	p.names = make(map[string]bool)
+	p.requested = make(map[string]RequestNameFlags)
+	p.exports = make(map[string]map[string]*exportedInterface)
+	p.managers = make(map[string]bool)
+	p.calls = new(vector.Vector)
+	p.callReady = make(chan bool, 1)
 	p.proxy = p._GetProxy()
 	p.buffer = bytes.NewBuffer([]byte{})
-	p._Auth()
-	go p._RunLoop()
-	p._SendHello()
-	return nil
+	p.timeout = DefaultTimeout
+	p.closed = make(chan bool)
 }
 
-func (p *Connection) _Auth() os.Error {
+func (p *Connection) _Auth(conn net.Conn) os.Error {
 	auth := new(authState)
 	auth.AddAuthenticator(new(AuthExternal))
 
-	return auth.Authenticate(p.conn)
+	return auth.Authenticate(conn)
 }
 
 func (p *Connection) _MessageReceiver(msgChan chan *Message) {
 	for {
 		msg, e := p._PopMessage()
 		if e == nil {
-			msgChan <- msg
+			select {
+			case msgChan <- msg:
+			case <-p.closed:
+				return
+			}
 			continue // might be another msg in p.buffer
 		}
-		p._UpdateBuffer()
+		if e = p._UpdateBuffer(); e != nil {
+			if p._Reconnect() {
+				continue
+			}
+			p._Shutdown(e)
+			return
+		}
 	}
 }
 
 func (p *Connection) _RunLoop() {
 	msgChan := make(chan *Message)
 	go p._MessageReceiver(msgChan)
+	go p._CallWorker()
 	for {
 		select {
 		case msg := <-msgChan:
 			p._MessageDispatch(msg)
+		case <-p.closed:
+			return
 		}
 	}
 }
 
+// Close shuts the connection down. Pending method calls fail with a
+// Disconnected error and later calls fail with ErrClosed.
+func (p *Connection) Close() os.Error {
+	p._Shutdown(ErrClosed)
+	return nil
+}
+
+// Done returns a channel that is closed when the connection shuts down,
+// either through Close or because the peer hung up.
+func (p *Connection) Done() <-chan bool { return p.closed }
+
+// Err returns the reason the connection shut down: ErrClosed after Close,
+// the read error after a hang-up, or nil while the connection is open.
+func (p *Connection) Err() os.Error {
+	p.replyMutex.Lock()
+	defer p.replyMutex.Unlock()
+	return p.err
+}
+
+// UniqueName returns the unique name, such as ":1.42", the bus assigned
+// to the connection in reply to Hello. It changes after a reconnect.
+func (p *Connection) UniqueName() string {
+	p.replyMutex.Lock()
+	defer p.replyMutex.Unlock()
+	return p.uniqName
+}
+
+func (p *Connection) _Shutdown(err os.Error) {
+	p.replyMutex.Lock()
+	if p.isClosed {
+		p.replyMutex.Unlock()
+		return
+	}
+	p.isClosed = true
+	p.err = err
+	p.replyMutex.Unlock()
+
+	close(p.closed)
+	p._GetConn().Close()
+	p._FailPending()
+	p._CloseSignalChannels()
+}
+
+// _FailPending answers every pending call with a Disconnected error.
+func (p *Connection) _FailPending() {
+	p.replyMutex.Lock()
+	replies := p.methodCallReplies
+	p.methodCallReplies = make(map[uint32]func(*Message))
+	p.replyMutex.Unlock()
+
+	for seri, replyFunc := range replies {
+		replyFunc(_NewErrorReply(seri, errorDisconnected, "Connection is closed"))
+	}
+}
+
+func (p *Connection) _IsClosed() bool {
+	p.replyMutex.Lock()
+	defer p.replyMutex.Unlock()
+	return p.isClosed
+}
+
+func (p *Connection) _GetConn() net.Conn {
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+	return p.conn
+}
+
+func (p *Connection) _SetConn(conn net.Conn) {
+	p.writeMutex.Lock()
+	p.conn = conn
+	p.writeMutex.Unlock()
+}
+
 func (p *Connection) _MessageDispatch(msg *Message) {
 	if msg == nil {
 		return
 	}
 
 	switch msg.Type {
-	case METHOD_RETURN:
-		rs := msg.replySerial
-		if replyFunc, ok := p.methodCallReplies[rs]; ok {
+	case METHOD_RETURN, ERROR:
+		// eavesdropped replies to other connections may reuse our serials
+		if !p._IsForUs(msg) {
+			break
+		}
+		// replies nobody waits for any more, such as late answers to calls
+		// that timed out, only reach message handlers
+		if replyFunc := p._PopReplyFunc(msg.replySerial); replyFunc != nil {
 			replyFunc(msg)
-			p.methodCallReplies[rs] = nil, false
 		}
-  case SIGNAL:
-		for v := range p.signalMatchRules.Iter() {
-			handler := v.(signalHandler)
-			if handler.mr._Match(msg) {
-				handler.proc(msg)
-			}
+	case METHOD_CALL:
+		if p._IsForUs(msg) {
+			p._QueueCall(msg)
 		}
-	case ERROR:
-		fmt.Println("ERROR")
-		fmt.Printf("%#v\n", msg)
+	case SIGNAL:
+		p._TrackNames(msg)
+	}
+
+	// callbacks run without the handler lock, but on the run loop: any
+	// call that waits for a reply, including adding or removing a handler,
+	// would wait for this loop and never return
+	for _, proc := range p._DeliverMessage(msg) {
+		proc(msg)
+	}
+}
+
+// _QueueCall hands call to the call worker. The queue is unbounded, so
+// that the run loop never waits for a method to finish.
+func (p *Connection) _QueueCall(call *Message) {
+	p.callMutex.Lock()
+	p.calls.Push(call)
+	p.callMutex.Unlock()
+
+	select {
+	case p.callReady <- true:
+	default: // the worker has been woken already
+	}
+}
+
+// _CallWorker answers the queued calls one at a time, in the order they
+// arrived, until the connection shuts down.
+func (p *Connection) _CallWorker() {
+	for {
+		select {
+		case <-p.callReady:
+		case <-p.closed:
+			return
+		}
+		for call := p._PopCall(); call != nil; call = p._PopCall() {
+			p._HandleCall(call)
+		}
+	}
+}
+
+func (p *Connection) _PopCall() *Message {
+	p.callMutex.Lock()
+	defer p.callMutex.Unlock()
+
+	if p.calls.Len() == 0 {
+		return nil
+	}
+	call := p.calls.At(0).(*Message)
+	p.calls.Delete(0)
+	return call
+}
+
+// _IsForUs reports whether msg is addressed to this connection, by its
+// unique name or a well-known name it owns, rather than received by
+// eavesdropping. Before Hello has been answered the unique name is
+// unknown and everything is accepted.
+func (p *Connection) _IsForUs(msg *Message) bool {
+	p.replyMutex.Lock()
+	defer p.replyMutex.Unlock()
+
+	return msg.Dest == "" || p.uniqName == "" || msg.Dest == p.uniqName || p.names[msg.Dest]
+}
+
+// _PopReplyFunc removes and returns the reply handler registered for
+// seri, or nil if there is none.
+func (p *Connection) _PopReplyFunc(seri uint32) func(*Message) {
+	p.replyMutex.Lock()
+	defer p.replyMutex.Unlock()
+
+	replyFunc, ok := p.methodCallReplies[seri]
+	if !ok {
+		return nil
 	}
+	p.methodCallReplies[seri] = nil, false
+	return replyFunc
 }
 
 func (p *Connection) _PopMessage() (*Message, os.Error) {
@@ -223,6 +483,9 @@ func (p *Connection) _PopMessage() (*Message, os.Error) {
 	if err != nil {
 		return nil, err
 	}
+	if capture := p._GetCapture(); capture != nil {
+		capture._WritePacket(p.buffer.Bytes()[0:n])
+	}
 	p.buffer.Read(make([]byte, n)) // remove first n bytes
 	return msg, nil
 }
@@ -236,25 +499,195 @@ func (p *Connection) _UpdateBuffer() os.Error {
 }
 
 func (p *Connection) _SendSync(msg *Message, callback func(*Message)) os.Error {
-	seri := uint32(msg.serial)
+	return p._SendSyncCancel(msg, 0, nil, callback)
+}
+
+// _SendSyncCancel is like _SendSync, with the timeout and cancel of
+// _SendAsyncCancel.
+func (p *Connection) _SendSyncCancel(msg *Message, timeout int64, cancel <-chan bool, callback func(*Message)) os.Error {
 	recvChan := make(chan int)
-	p.methodCallReplies[seri] = func(rmsg *Message) {
+	e := p._SendAsyncCancel(msg, timeout, cancel, func(rmsg *Message) {
 		callback(rmsg)
 		recvChan <- 0
+	})
+	if e != nil {
+		return e
 	}
-
-	buff, _ := msg._Marshal()
-	p.conn.Write(buff)
 	<-recvChan // synchronize
 	return nil
 }
 
-func (p *Connection) _SendHello() os.Error {
-	p.CallMethod(p.proxy, "Hello")
+// _SendAsync sends msg and arranges for callback to be invoked from the
+// run loop with the METHOD_RETURN or ERROR that answers it.
+func (p *Connection) _SendAsync(msg *Message, callback func(*Message)) os.Error {
+	return p._SendAsyncCancel(msg, 0, nil, callback)
+}
+
+// _SendAsyncCancel is like _SendAsync, but if no reply arrives within
+// timeout nanoseconds, or cancel becomes readable first, callback
+// receives a synthetic NoReply error instead. A timeout of 0 uses the
+// connection default and a negative one never expires.
+func (p *Connection) _SendAsyncCancel(msg *Message, timeout int64, cancel <-chan bool, callback func(*Message)) os.Error {
+	seri := uint32(msg.serial)
+	replied := make(chan bool, 1)
+	p.replyMutex.Lock()
+	if p.isClosed {
+		p.replyMutex.Unlock()
+		return ErrClosed
+	}
+	p.methodCallReplies[seri] = func(rmsg *Message) {
+		replied <- true
+		callback(rmsg)
+	}
+	p.replyMutex.Unlock()
+
+	if e := p._SendMessage(msg); e != nil {
+		if p._PopReplyFunc(seri) == nil {
+			return nil // already failed by _Shutdown
+		}
+		return e
+	}
+
+	if timeout == 0 {
+		timeout = p._Timeout()
+	}
+	if 0 < timeout || cancel != nil {
+		go p._WatchCall(seri, timeout, cancel, replied)
+	}
 	return nil
 }
 
-func (p *Connection) _GetIntrospect(dest string, path string) Introspect {
+// _WatchCall fails the call seri when its timeout expires or cancel
+// becomes readable. It returns as soon as the reply arrives; the sleeping
+// goroutine then finishes on its own, without blocking on the buffered
+// channel, once the timeout has passed.
+func (p *Connection) _WatchCall(seri uint32, timeout int64, cancel <-chan bool, replied <-chan bool) {
+	expired := make(chan bool, 1)
+	if 0 < timeout {
+		go func() {
+			time.Sleep(timeout)
+			expired <- true
+		}()
+	}
+	if cancel == nil {
+		cancel = make(chan bool)
+	}
+
+	select {
+	case <-replied:
+	case <-expired:
+		p._ExpireCall(seri, "Did not receive a reply. Possible causes include: the remote application did not send a reply, the message bus security policy blocked the reply, the reply timeout expired, or the network connection was broken.")
+	case <-cancel:
+		p._ExpireCall(seri, "Method call was cancelled")
+	}
+}
+
+// _ExpireCall drops the pending reply handler for seri, if it is still
+// registered, and completes it with a NoReply error.
+func (p *Connection) _ExpireCall(seri uint32, reason string) {
+	if replyFunc := p._PopReplyFunc(seri); replyFunc != nil {
+		replyFunc(_NewErrorReply(seri, errorNoReply, reason))
+	}
+}
+
+// _NewErrorReply synthesizes the ERROR a pending call sees when it is
+// answered locally rather than by its peer.
+func _NewErrorReply(seri uint32, name string, reason string) *Message {
+	msg := NewMessage()
+	msg.Type = ERROR
+	msg.ErrorName = name
+	msg.replySerial = seri
+	msg.Sig = "s"
+	msg.Params.Push(reason)
+	return msg
+}
+
+// SetTimeout sets the default time in nanoseconds method calls wait for
+// a reply. Zero restores DefaultTimeout and a negative value lets calls
+// wait forever. Calls given their own timeout, as with CallMethodCancel,
+// use it instead.
+func (p *Connection) SetTimeout(nsec int64) {
+	if nsec == 0 {
+		nsec = DefaultTimeout
+	}
+	p.replyMutex.Lock()
+	p.timeout = nsec
+	p.replyMutex.Unlock()
+}
+
+func (p *Connection) _Timeout() int64 {
+	p.replyMutex.Lock()
+	defer p.replyMutex.Unlock()
+	return p.timeout
+}
+
+func (p *Connection) _SendMessage(msg *Message) os.Error {
+	buff, e := msg._Marshal()
+	if e != nil {
+		return e
+	}
+
+	if p._IsClosed() {
+		return ErrClosed
+	}
+
+	// a single locked write keeps concurrent messages from interleaving
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+
+	if capture := p._GetCapture(); capture != nil {
+		capture._WritePacket(buff)
+	}
+	_, e = p.conn.Write(buff)
+	return e
+}
+
+// Send writes msg to the bus without waiting for a reply. A nil msg, as
+// returned by NewMethodReturn for NO_REPLY_EXPECTED calls, is ignored.
+func (p *Connection) Send(msg *Message) os.Error {
+	if msg == nil {
+		return nil
+	}
+	return p._SendMessage(msg)
+}
+
+// SetCapture records every message sent and received on the connection
+// with w. A nil w stops recording.
+func (p *Connection) SetCapture(w *PcapWriter) {
+	p.captureMutex.Lock()
+	p.capture = w
+	p.captureMutex.Unlock()
+}
+
+func (p *Connection) _GetCapture() *PcapWriter {
+	p.captureMutex.Lock()
+	defer p.captureMutex.Unlock()
+	return p.capture
+}
+
+func (p *Connection) _SendHello() os.Error {
+	ret, e := p.CallMethod(p.proxy, "Hello")
+	if e != nil {
+		return e
+	}
+	if 0 < len(ret) {
+		if name, ok := ret[0].(string); ok {
+			p._SetUniqName(name)
+			return nil
+		}
+	}
+	return os.NewError("Invalid Hello reply")
+}
+
+func (p *Connection) _SetUniqName(name string) {
+	p.replyMutex.Lock()
+	p.uniqName = name
+	p.replyMutex.Unlock()
+}
+
+// _Introspect calls Introspect on path and parses the reply. cancel and
+// timeout bound the call as with CallMethodCancel.
+func (p *Connection) _Introspect(cancel <-chan bool, timeout int64, dest string, path string) (Introspect, os.Error) {
 	msg := NewMessage()
 	msg.Type = METHOD_CALL
 	msg.Path = path
@@ -263,16 +696,23 @@ func (p *Connection) _GetIntrospect(dest string, path string) Introspect {
 	msg.Member = "Introspect"
 
 	var intro Introspect
+	var err os.Error
 
-	p._SendSync(msg, func(reply *Message) {
-		if v, ok := reply.Params.At(0).(string); ok {
-			if i, err := NewIntrospect(v); err == nil {
-				intro = i
-			}
+	e := p._SendSyncCancel(msg, timeout, cancel, func(reply *Message) {
+		if reply.Type == ERROR {
+			err = &Error{reply.ErrorName, reply.Params.Data()}
+			return
+		}
+		if v, ok := _StringArg(reply, 0); ok {
+			intro, err = NewIntrospect(v)
+		} else {
+			err = os.NewError("Invalid Introspect reply")
 		}
 	})
-
-	return intro
+	if e != nil {
+		return nil, e
+	}
+	return intro, err
 }
 
 func (p *Connection) Interface(obj *Object, name string) *Interface {
@@ -310,6 +750,7 @@ func _ArgToVector(args ...) *vector.Vector {
 
 func (p *Connection) _GetProxy() *Interface {
 	obj := new(Object)
+	obj.conn = p
 	obj.path = "/org/freedesktop/DBus"
 	obj.dest = "org.freedesktop.DBus"
 	obj.intro,_ = NewIntrospect(dbusXMLIntro)
@@ -323,28 +764,17 @@ func (p *Connection) _GetProxy() *Interface {
 }
 
 func (p *Connection) CallMethod(iface *Interface, name string, args ...) ([]interface{}, os.Error) {
+	return p.CallMethodCancel(nil, 0, iface, name, args)
+}
 
-	method := iface.intro.GetMethodData(name)
-	if nil == method {
-		return nil, os.NewError("Invalid Method")
-	}
-
-	msg := NewMessage()
-
-	msg.Type = METHOD_CALL
-	msg.Path = iface.obj.path
-	msg.Iface = iface.name
-	msg.Dest = iface.obj.dest
-	msg.Member = name
-	msg.Sig = method.GetInSignature()
-	msg.Params.AppendVector(_ArgToVector(args))
-
-	var ret []interface{}
-	p._SendSync(msg, func(reply *Message) { 
-		fmt.Println("CallMethodRet: " , reply.Params.Data())
-		ret = reply.Params.Data()})
-
-	return ret,nil
+// CallMethodCancel is like CallMethod, but fails with a NoReply error
+// after timeout nanoseconds, or as soon as cancel becomes readable, for
+// example because it was closed. A timeout of 0 uses the connection
+// default set with SetTimeout and a negative one never expires, so that
+// only cancel ends the call.
+func (p *Connection) CallMethodCancel(cancel <-chan bool, timeout int64, iface *Interface, name string, args ...) ([]interface{}, os.Error) {
+	call := <-p.GoCancel(cancel, timeout, iface, name, make(chan *Call, 1), args).Done
+	return call.Body, call.Err
 }
 
 func (p *Connection) EmitSignal(iface *Interface, name string, args ...) os.Error{
@@ -364,23 +794,47 @@ func (p *Connection) EmitSignal(iface *Interface, name string, args ...) os.Erro
 	msg.Sig = signal.GetSignature()
 	msg.Params.AppendVector(_ArgToVector(args))
 
-	buff, _ := msg._Marshal()
-	_,err := p.conn.Write(buff)
-
-	return err
+	return p._SendMessage(msg)
 }
 
 func(p *Connection) GetObject(dest string, path string) *Object{
+	obj, _ := p.GetObjectCancel(nil, 0, dest, path)
+	return obj
+}
 
+// GetObjectCancel is like GetObject, but bounded by cancel and timeout as
+// with CallMethodCancel. It also returns the error introspecting the
+// object; the Object is returned either way, without introspection data
+// if that failed.
+func (p *Connection) GetObjectCancel(cancel <-chan bool, timeout int64, dest string, path string) (*Object, os.Error) {
 	obj := new(Object)
+	obj.conn = p
 	obj.path = path
 	obj.dest = dest
-	obj.intro = p._GetIntrospect(dest, path)
 
-	return obj
+	intro, e := p._Introspect(cancel, timeout, dest, path)
+	obj.intro = intro
+	return obj, e
+}
+
+// GetPath returns the object path of the object.
+func (p *Object) GetPath() string { return p.path }
+
+// GetIntrospect returns the introspection data of the object, or nil if
+// it could not be introspected.
+func (p *Object) GetIntrospect() Introspect { return p.intro }
+
+// AddSignalHandler calls proc for every signal matching mr. proc runs on
+// the run loop and delays every other message while it executes. It must
+// not call methods, add or remove handlers or otherwise wait for a reply,
+// which only the run loop can deliver; use Subscribe for slow consumers
+// or ones that do. If the bus rejects the rule no handler is installed
+// and the error is returned.
+func(p *Connection) AddSignalHandler(mr *MatchRule, proc func(*Message)) (*SignalHandle, os.Error) {
+	return p._AddHandler(&signalHandler{mr: *mr, proc: proc})
 }
 
-func(p *Connection) AddSignalHandler(mr *MatchRule, proc func(*Message)) {
-	p.signalMatchRules.Push(signalHandler{*mr, proc})
-	p.CallMethod(p.proxy, "AddMatch", mr._ToString())
+// RemoveSignalHandler unregisters a handler added with AddSignalHandler.
+func(p *Connection) RemoveSignalHandler(handle *SignalHandle) os.Error {
+	return handle.Remove()
 }
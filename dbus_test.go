@@ -1,7 +1,13 @@
-
 package dbus
 
 import (
+	"bytes"
+	"container/vector"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"fmt"
 )
@@ -22,3 +28,421 @@ func TestDbus(t *testing.T){
 
 	
 }
+
+// pipeConn is one end of an in-memory connection to a testBus.
+type pipeConn struct {
+	r *io.PipeReader
+	w *io.PipeWriter
+}
+
+func (p *pipeConn) Read(b []byte) (int, os.Error)  { return p.r.Read(b) }
+func (p *pipeConn) Write(b []byte) (int, os.Error) { return p.w.Write(b) }
+func (p *pipeConn) Close() os.Error {
+	p.r.Close()
+	return p.w.Close()
+}
+func (p *pipeConn) LocalAddr() net.Addr { return nil }
+func (p *pipeConn) RemoteAddr() net.Addr { return nil }
+func (p *pipeConn) SetTimeout(nsec int64) os.Error { return nil }
+func (p *pipeConn) SetReadTimeout(nsec int64) os.Error { return nil }
+func (p *pipeConn) SetWriteTimeout(nsec int64) os.Error { return nil }
+
+// testBus is a minimal stand-in for the message bus daemon: it answers
+// every method call and can emit signals.
+type testBus struct {
+	conn    *pipeConn
+	mutex   sync.Mutex
+	matches vector.StringVector // rules added with AddMatch
+	removed vector.StringVector // rules removed with RemoveMatch
+	names   vector.StringVector // names requested with RequestName
+	owners  map[string]string   // answers to GetNameOwner
+	onCall  func(*Message)      // called before a method call is answered
+	refused string              // method answered with an AccessDenied error
+	peer    *testBus            // forwarded messages not addressed to the bus
+}
+
+// newTestBus returns a bus and the client end of a connection to it.
+func newTestBus() (*testBus, *pipeConn) {
+	clientR, busW := io.Pipe()
+	busR, clientW := io.Pipe()
+
+	return &testBus{conn: &pipeConn{busR, busW}}, &pipeConn{clientR, clientW}
+}
+
+func newTestConnection() (*Connection, *testBus) {
+	bus, conn := newTestBus()
+	go bus._Serve([]byte{})
+
+	con := new(Connection)
+	con.conn = conn
+	con._Init()
+	go con._RunLoop()
+
+	return con, bus
+}
+
+func (p *testBus) _Send(msg *Message) {
+	buff, _ := msg._Marshal()
+	p._Write(buff)
+}
+
+func (p *testBus) _Write(buff []byte) {
+	p.mutex.Lock()
+	p.conn.Write(buff)
+	p.mutex.Unlock()
+}
+
+// _ServeAuth accepts any authentication and returns whatever the
+// client sent after BEGIN.
+func (p *testBus) _ServeAuth() []byte {
+	buffer := bytes.NewBuffer([]byte{})
+	b := make([]byte, 4096)
+	accepted := false
+	for {
+		str := buffer.String()
+		if !accepted && 0 <= strings.Index(str, "\r\n") {
+			p.conn.Write(strings.Bytes("OK 0123456789abcdef\r\n"))
+			accepted = true
+		}
+		if i := strings.Index(str, "BEGIN\r\n"); 0 <= i {
+			return buffer.Bytes()[i+7 : buffer.Len()]
+		}
+		n, e := p.conn.Read(b)
+		if e != nil {
+			return []byte{}
+		}
+		buffer.Write(b[0:n])
+	}
+	panic("unreachable")
+}
+
+func (p *testBus) _Serve(initial []byte) {
+	buffer := bytes.NewBuffer(initial)
+	b := make([]byte, 4096)
+	for {
+		msg, n, e := _Unmarshal(buffer.Bytes())
+		if e != nil {
+			rn, re := p.conn.Read(b)
+			if re != nil {
+				return
+			}
+			buffer.Write(b[0:rn])
+			continue
+		}
+		raw := make([]byte, n)
+		buffer.Read(raw)
+
+		if p.peer != nil && "org.freedesktop.DBus" != msg.Dest {
+			p.peer._Write(raw)
+			continue
+		}
+		if msg.Type == METHOD_CALL {
+			if p.onCall != nil {
+				p.onCall(msg)
+			}
+			p._Send(p._Reply(msg))
+		}
+	}
+}
+
+func (p *testBus) _Reply(call *Message) *Message {
+	if call.Member == p.refused {
+		return NewError(call, "org.freedesktop.DBus.Error.AccessDenied", "s", "refused")
+	}
+	switch call.Member {
+	case "Hello":
+		return NewMethodReturn(call, "s", ":1.1")
+	case "AddMatch":
+		p.mutex.Lock()
+		p.matches.Push(call.Params.At(0).(string))
+		p.mutex.Unlock()
+	case "RemoveMatch":
+		p.mutex.Lock()
+		p.removed.Push(call.Params.At(0).(string))
+		p.mutex.Unlock()
+	case "ListNames":
+		names := new(vector.Vector)
+		names.Push("org.freedesktop.DBus")
+		return NewMethodReturn(call, "as", names)
+	case "ListActivatableNames":
+		// a reply of the wrong type
+		return NewMethodReturn(call, "s", "org.freedesktop.DBus")
+	case "ListQueuedOwners":
+		owners := new(vector.Vector)
+		owners.Push(call.Params.At(0).(string))
+		return NewMethodReturn(call, "as", owners)
+	case "NameHasOwner":
+		return NewMethodReturn(call, "b", call.Params.At(0).(string) == "org.freedesktop.DBus")
+	case "GetNameOwner":
+		name := call.Params.At(0).(string)
+		if "org.freedesktop.DBus" == name {
+			return NewMethodReturn(call, "s", name)
+		}
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		if owner, ok := p.owners[name]; ok {
+			return NewMethodReturn(call, "s", owner)
+		}
+		return NewError(call, "org.freedesktop.DBus.Error.NameHasNoOwner", "s", "no owner")
+	case "GetId":
+		return NewMethodReturn(call, "s", "0123456789abcdef0123456789abcdef")
+	case "GetConnectionUnixUser":
+		return NewMethodReturn(call, "u", uint32(1000))
+	case "GetConnectionCredentials":
+		if ":1.2" == call.Params.At(0) {
+			// a reply of the wrong type
+			return NewMethodReturn(call, "u", uint32(1000))
+		}
+		return NewMethodReturn(call, "a{sv}", map[string]interface{}{"UnixUserID": uint32(1000)})
+	case "GetAdtAuditSessionData":
+		return NewError(call, "org.freedesktop.DBus.Error.AdtAuditDataUnknown", "s", "no audit data")
+	case "RequestName":
+		// names under org.queued are owned by someone else
+		name := call.Params.At(0).(string)
+		p.mutex.Lock()
+		p.names.Push(name)
+		p.mutex.Unlock()
+		if strings.HasPrefix(name, "org.queued.") {
+			return NewMethodReturn(call, "u", uint32(REQUEST_NAME_REPLY_IN_QUEUE))
+		}
+		p._EmitNameSignal("NameAcquired", name)
+		return NewMethodReturn(call, "u", uint32(REQUEST_NAME_REPLY_PRIMARY_OWNER))
+	case "ReleaseName":
+		p._EmitNameSignal("NameLost", call.Params.At(0).(string))
+		return NewMethodReturn(call, "u", uint32(RELEASE_NAME_REPLY_RELEASED))
+	}
+	return NewMethodReturn(call, "")
+}
+
+func (p *testBus) _EmitNameOwnerChanged(name string, old string, owner string) *Message {
+	msg := NewMessage()
+	msg.Type = SIGNAL
+	msg.Sender = "org.freedesktop.DBus"
+	msg.Path = "/org/freedesktop/DBus"
+	msg.Iface = "org.freedesktop.DBus"
+	msg.Member = "NameOwnerChanged"
+	msg.Sig = "sss"
+	msg.Params.Push(name)
+	msg.Params.Push(old)
+	msg.Params.Push(owner)
+	p._Send(msg)
+	return msg
+}
+
+func (p *testBus) _EmitNameSignal(member string, name string) {
+	msg := NewMessage()
+	msg.Type = SIGNAL
+	msg.Sender = "org.freedesktop.DBus"
+	msg.Dest = ":1.1"
+	msg.Path = "/org/freedesktop/DBus"
+	msg.Iface = "org.freedesktop.DBus"
+	msg.Member = member
+	msg.Sig = "s"
+	msg.Params.Push(name)
+	p._Send(msg)
+}
+
+func (p *testBus) _EmitSignal(member string) {
+	msg := NewMessage()
+	msg.Type = SIGNAL
+	msg.Sender = "org.freedesktop.DBus"
+	msg.Path = "/org/test"
+	msg.Iface = "org.test"
+	msg.Member = member
+	p._Send(msg)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	con, bus := newTestConnection()
+
+	const workers = 8
+	const count = 50
+
+	var mutex sync.Mutex
+	received := 0
+	handler := func(msg *Message) {
+		mutex.Lock()
+		received++
+		mutex.Unlock()
+	}
+
+	done := make(chan bool)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := 0; j < count; j++ {
+				ret, e := con.CallMethod(con.proxy, "ListNames")
+				if e != nil || 1 != len(ret) {
+					t.Error("#1 Failed:", e)
+				}
+			}
+			done <- true
+		}()
+		go func() {
+			for j := 0; j < count; j++ {
+				con.AddSignalHandler(&MatchRule{Type: "signal", Interface: "org.test"}, handler)
+			}
+			done <- true
+		}()
+		go func() {
+			for j := 0; j < count; j++ {
+				bus._EmitSignal("Tick")
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < 3*workers; i++ {
+		<-done
+	}
+
+	// the connection still answers after the storm
+	if _, e := con.CallMethod(con.proxy, "ListNames"); e != nil {
+		t.Error("#2 Failed:", e)
+	}
+	if 0 != len(con.methodCallReplies) {
+		t.Error("#3 Failed")
+	}
+}
+
+func TestClose(t *testing.T) {
+	con := newDiscardConnection()
+	go con._RunLoop()
+
+	call := con.Go(con.proxy, "ListNames", nil)
+	con.Close()
+	<-call.Done
+
+	if err, ok := call.Err.(*Error); !ok || errorDisconnected != err.Name {
+		t.Error("#1 Failed:", call.Err)
+	}
+	<-con.Done()
+	if ErrClosed != con.Err() {
+		t.Error("#2 Failed:", con.Err())
+	}
+	if _, e := con.CallMethod(con.proxy, "ListNames"); ErrClosed != e {
+		t.Error("#3 Failed:", e)
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	bus, conn := newTestBus()
+	go func() { bus._Serve(bus._ServeAuth()) }()
+
+	con := new(Connection)
+	con.conn = conn
+	if e := con.Initialize(); e != nil {
+		t.Fatal("#1 Failed:", e)
+	}
+	if ":1.1" != con.UniqueName() {
+		t.Error("#2 Failed:", con.UniqueName())
+	}
+
+	bus, conn = newTestBus()
+	bus.refused = "Hello"
+	go func() { bus._Serve(bus._ServeAuth()) }()
+
+	con = new(Connection)
+	con.conn = conn
+	if e := con.Initialize(); e == nil {
+		t.Error("#3 Failed")
+	}
+	if "" != con.UniqueName() {
+		t.Error("#4 Failed:", con.UniqueName())
+	}
+
+	// the failed connection is torn down, not left half alive
+	<-con.Done()
+	if ErrClosed != con.Err() {
+		t.Error("#5 Failed:", con.Err())
+	}
+	if _, e := con.ListNames(); e == nil {
+		t.Error("#6 Failed")
+	}
+	if _, e := bus.conn.Read(make([]byte, 1)); e == nil {
+		t.Error("#7 Failed")
+	}
+}
+
+func TestPeerHangUp(t *testing.T) {
+	con, bus := newTestConnection()
+	if nil != con.Err() {
+		t.Error("#1 Failed")
+	}
+
+	bus.conn.Close()
+	<-con.Done()
+	if os.EOF != con.Err() {
+		t.Error("#2 Failed:", con.Err())
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	con, bus := newTestConnection()
+
+	buses := make(chan *testBus, 1)
+	con.dial = func() (net.Conn, os.Error) {
+		newBus, conn := newTestBus()
+		go func() { newBus._Serve(newBus._ServeAuth()) }()
+		buses <- newBus
+		return conn, nil
+	}
+	names := make(chan string, 1)
+	con.SetReconnectPolicy(&ReconnectPolicy{
+		InitialDelay: 1e6,
+		OnReconnect: func(name string, e os.Error) {
+			if e != nil {
+				name = e.String()
+			}
+			names <- name
+		},
+	})
+
+	mr := &MatchRule{Type: "signal", Interface: "org.test"}
+	con.AddSignalHandler(mr, func(msg *Message) {})
+
+	bus.conn.Close()
+	if name := <-names; ":1.1" != name {
+		t.Error("#1 Failed:", name)
+	}
+
+	newBus := <-buses
+	newBus.mutex.Lock()
+	if 1 != newBus.matches.Len() || mr._ToString() != newBus.matches.At(0) {
+		t.Error("#2 Failed:", newBus.matches.Data())
+	}
+	newBus.mutex.Unlock()
+
+	if _, e := con.CallMethod(con.proxy, "ListNames"); e != nil {
+		t.Error("#3 Failed:", e)
+	}
+	if nil != con.Err() {
+		t.Error("#4 Failed:", con.Err())
+	}
+}
+
+func TestReconnectRestoreFailed(t *testing.T) {
+	con, bus := newTestConnection()
+
+	con.dial = func() (net.Conn, os.Error) {
+		newBus, conn := newTestBus()
+		newBus.refused = "AddMatch"
+		go func() { newBus._Serve(newBus._ServeAuth()) }()
+		return conn, nil
+	}
+	errs := make(chan os.Error, 1)
+	con.SetReconnectPolicy(&ReconnectPolicy{
+		InitialDelay: 1e6,
+		OnReconnect:  func(name string, e os.Error) { errs <- e },
+	})
+
+	mr := &MatchRule{Type: "signal", Interface: "org.test"}
+	con.AddSignalHandler(mr, func(msg *Message) {})
+
+	bus.conn.Close()
+	e, ok := (<-errs).(*ReregisterError)
+	if !ok {
+		t.Fatal("#1 Failed:", e)
+	}
+	if 1 != len(e.Rules) || nil == e.Rules[mr._ToString()] || 0 != len(e.Names) {
+		t.Error("#2 Failed:", e)
+	}
+}
@@ -0,0 +1,545 @@
+package dbus
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	errorFailed           = "org.freedesktop.DBus.Error.Failed"
+	errorUnknownObject    = "org.freedesktop.DBus.Error.UnknownObject"
+	errorUnknownInterface = "org.freedesktop.DBus.Error.UnknownInterface"
+	errorUnknownMethod    = "org.freedesktop.DBus.Error.UnknownMethod"
+	errorInvalidArgs      = "org.freedesktop.DBus.Error.InvalidArgs"
+)
+
+var osErrorType = reflect.Typeof((*os.Error)(nil)).(*reflect.PtrType).Elem()
+
+const introspectableXMLIntro = `
+<node>
+  <interface name="org.freedesktop.DBus.Introspectable">
+    <method name="Introspect">
+      <arg name="xml_data" type="s" direction="out"/>
+    </method>
+  </interface>
+</node>`
+
+var introspectableIntro, _ = NewIntrospect(introspectableXMLIntro)
+
+// An exportedInterface is a Go value serving one interface of an object,
+// with the store of its properties if it has any. Only the methods of obj
+// described by intro can be called.
+type exportedInterface struct {
+	obj     interface{}
+	intro   interfaceData
+	methods map[string]*exportedMethod
+	props   *PropertyStore
+}
+
+// An exportedMethod is a Go method callable over the bus, with the
+// signature its arguments must have.
+type exportedMethod struct {
+	fn  *reflect.FuncValue
+	sig string
+}
+
+// _MethodsOf resolves the methods described by intro to those of obj.
+// Methods obj does not have are left out and cannot be called.
+func _MethodsOf(obj interface{}, intro interfaceData) map[string]*exportedMethod {
+	methods := make(map[string]*exportedMethod)
+	for _, method := range intro.Method {
+		if fn := _FindMethod(obj, method.Name); fn != nil {
+			methods[method.Name] = &exportedMethod{fn, method.GetInSignature()}
+		}
+	}
+	return methods
+}
+
+// Export makes the exported methods of v callable as methods of iface on
+// the object at path. Arguments are converted to the Go parameter types;
+// the results are sent back in a method return, except for a trailing
+// os.Error which, if non-nil, is sent as an ERROR instead. Returning an
+// *Error chooses the error name; any other error is reported as
+// org.freedesktop.DBus.Error.Failed.
+//
+// Methods run one at a time, in the order the calls arrive, on a
+// goroutine of their own rather than the run loop. They may call methods
+// of other connections, but a method waiting for a call to an object of
+// its own connection never returns. Only methods whose arguments and results have D-Bus types
+// are exported; they are introspected with unnamed arguments. Use
+// ExportWithIntrospection to describe the interface fully or to export
+// fewer methods.
+func (p *Connection) Export(v interface{}, path string, iface string) os.Error {
+	return p._Export(v, path, _InterfaceDataOf(v, iface))
+}
+
+// ExportWithIntrospection is like Export, but introspects the interface
+// as described by intro, including argument names, signals, properties
+// and annotations. Only the methods of v that intro describes can be
+// called. intro is typically obtained with NewIntrospect and
+// GetInterfaceData; its name is the name of the exported interface.
+func (p *Connection) ExportWithIntrospection(v interface{}, path string, intro InterfaceData) os.Error {
+	data, ok := intro.(interfaceData)
+	if !ok {
+		return os.NewError("Unsupported InterfaceData")
+	}
+	return p._Export(v, path, data)
+}
+
+func (p *Connection) _Export(v interface{}, path string, intro interfaceData) os.Error {
+	if !strings.HasPrefix(path, "/") {
+		return os.NewError("Invalid object path")
+	}
+	if intro.Name == "" {
+		return os.NewError("Invalid interface name")
+	}
+
+	p.exportMutex.Lock()
+	ifaces, ok := p.exports[path]
+	if !ok {
+		ifaces = make(map[string]*exportedInterface)
+		p.exports[path] = ifaces
+	}
+	exported := &exportedInterface{obj: v, intro: intro, methods: _MethodsOf(v, intro)}
+	old, found := ifaces[intro.Name]
+	if found && old.props != nil {
+		exported.props = old.props
+		if len(intro.Property) == 0 {
+			exported.intro.Property = old.intro.Property
+		}
+	}
+	ifaces[intro.Name] = exported
+	p.exportMutex.Unlock()
+
+	if !found {
+		p._EmitInterfacesAdded(path, intro.Name)
+	}
+	return nil
+}
+
+// Unexport stops serving iface on the object at path, or the whole
+// object if iface is "".
+func (p *Connection) Unexport(path string, iface string) os.Error {
+	p.exportMutex.Lock()
+	ifaces, ok := p.exports[path]
+	if !ok {
+		p.exportMutex.Unlock()
+		return os.NewError("No such object path")
+	}
+
+	var removed []string
+	if iface == "" {
+		removed = make([]string, len(ifaces))
+		i := 0
+		for name, _ := range ifaces {
+			removed[i] = name
+			i++
+		}
+		p.exports[path] = nil, false
+	} else {
+		if _, ok := ifaces[iface]; !ok {
+			p.exportMutex.Unlock()
+			return os.NewError("No such interface")
+		}
+		removed = []string{iface}
+		ifaces[iface] = nil, false
+		if len(ifaces) == 0 {
+			p.exports[path] = nil, false
+		}
+	}
+	p.exportMutex.Unlock()
+
+	p._EmitInterfacesRemoved(path, removed)
+	return nil
+}
+
+// _InterfaceDataOf describes the methods of v that have D-Bus types.
+func _InterfaceDataOf(v interface{}, name string) interfaceData {
+	data := interfaceData{Name: name}
+	if v == nil {
+		return data
+	}
+
+	val := reflect.NewValue(v)
+	t := val.Type()
+	data.Method = make([]methodData, 0, t.NumMethod())
+	for i := 0; i < t.NumMethod(); i++ {
+		if t.Method(i).PkgPath != "" {
+			continue
+		}
+		method, ok := _MethodDataOf(t.Method(i).Name, val.Method(i).Type().(*reflect.FuncType))
+		if ok {
+			n := len(data.Method)
+			data.Method = data.Method[0 : n+1]
+			data.Method[n] = method
+		}
+	}
+	return data
+}
+
+func _MethodDataOf(name string, ft *reflect.FuncType) (methodData, bool) {
+	method := methodData{Name: name}
+
+	nout := ft.NumOut()
+	if 0 < nout && ft.Out(nout-1) == osErrorType {
+		nout--
+	}
+	method.Arg = make([]argData, ft.NumIn()+nout)
+
+	for i := 0; i < ft.NumIn(); i++ {
+		sig, e := _SignatureOf(ft.In(i))
+		if e != nil {
+			return method, false
+		}
+		method.Arg[i] = argData{Type: sig, Direction: "in"}
+	}
+	for i := 0; i < nout; i++ {
+		sig, e := _SignatureOf(ft.Out(i))
+		if e != nil {
+			return method, false
+		}
+		method.Arg[ft.NumIn()+i] = argData{Type: sig, Direction: "out"}
+	}
+	return method, true
+}
+
+// _HandleCall answers a METHOD_CALL addressed to this connection. A
+// method that panics is answered with an error rather than bringing the
+// connection down.
+func (p *Connection) _HandleCall(call *Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.Send(NewError(call, errorFailed, "s", fmt.Sprintf("Method '%s' panicked: %v", call.Member, r)))
+		}
+	}()
+
+	if e := p.Send(p._CallExported(call)); e != nil && e != ErrClosed {
+		// most likely results that cannot be marshalled
+		p.Send(NewError(call, errorFailed, "s", e.String()))
+	}
+}
+
+func (p *Connection) _CallExported(call *Message) *Message {
+	if call.Iface == "org.freedesktop.DBus.Introspectable" && call.Member == "Introspect" {
+		return p._IntrospectReply(call)
+	}
+	if call.Iface == peerInterface {
+		return _PeerReply(call)
+	}
+	if call.Iface == objectManagerInterface && call.Member == "GetManagedObjects" {
+		if reply, ok := p._ManagedObjectsReply(call); ok {
+			return reply
+		}
+	}
+	if call.Iface == propertiesInterface && 0 < call.Params.Len() {
+		// interfaces without a store may implement Properties themselves
+		if iface, ok := call.Params.At(0).(string); ok {
+			if store := p._PropertyStore(call.Path, iface); store != nil {
+				return store._Reply(call)
+			}
+		}
+	}
+
+	method, reply := p._LookupMethod(call)
+	if method == nil {
+		return reply
+	}
+	if method.sig != call.Sig {
+		return NewError(call, errorInvalidArgs, "s",
+			fmt.Sprintf("Method '%s' takes arguments of type '%s', not '%s'", call.Member, method.sig, call.Sig))
+	}
+	return _CallMethodValue(call, method.fn)
+}
+
+// _LookupMethod finds the exported method a call refers to, or returns
+// the ERROR that answers it. A call without an interface goes to the
+// first interface on the object that has the method.
+func (p *Connection) _LookupMethod(call *Message) (*exportedMethod, *Message) {
+	p.exportMutex.RLock()
+	defer p.exportMutex.RUnlock()
+
+	ifaces, ok := p.exports[call.Path]
+	if !ok {
+		return nil, NewError(call, errorUnknownObject, "s",
+			fmt.Sprintf("No such object path '%s'", call.Path))
+	}
+
+	if call.Iface != "" {
+		exported, ok := ifaces[call.Iface]
+		if !ok {
+			return nil, NewError(call, errorUnknownInterface, "s",
+				fmt.Sprintf("No such interface '%s' at object path '%s'", call.Iface, call.Path))
+		}
+		if method, ok := exported.methods[call.Member]; ok {
+			return method, nil
+		}
+	} else {
+		for _, exported := range ifaces {
+			if method, ok := exported.methods[call.Member]; ok {
+				return method, nil
+			}
+		}
+	}
+
+	return nil, NewError(call, errorUnknownMethod, "s",
+		fmt.Sprintf("No such method '%s' in interface '%s' at object path '%s'", call.Member, call.Iface, call.Path))
+}
+
+// _IntrospectReply answers Introspect for an exported object, or for a
+// path above one so that the tree can be walked from "/".
+func (p *Connection) _IntrospectReply(call *Message) *Message {
+	intro, ok := p._IntrospectPath(call.Path)
+	if !ok {
+		return NewError(call, errorUnknownObject, "s",
+			fmt.Sprintf("No such object path '%s'", call.Path))
+	}
+	return NewMethodReturn(call, "s", intro._XML())
+}
+
+// _IntrospectPath describes the object at path: its interfaces and the
+// next component of every exported path below it.
+func (p *Connection) _IntrospectPath(path string) (*introspect, bool) {
+	p.exportMutex.RLock()
+	defer p.exportMutex.RUnlock()
+
+	ifaces, found := p.exports[path]
+	isManager := p.managers[path]
+
+	children := make(map[string]bool)
+	for exported, _ := range p.exports {
+		if child := _ChildNode(path, exported); child != "" {
+			children[child] = true
+		}
+	}
+	if !found && !isManager && len(children) == 0 {
+		return nil, false
+	}
+
+	names := make([]string, len(ifaces))
+	i := 0
+	for name, _ := range ifaces {
+		names[i] = name
+		i++
+	}
+	sort.SortStrings(names)
+
+	// interfaces the connection implements itself
+	standard := _AppendInterfaceData(introspectableIntro.(*introspect).Interface, peerIntro.(*introspect).Interface)
+	for _, exported := range ifaces {
+		if exported.props != nil {
+			standard = _AppendInterfaceData(standard, propertiesIntro.(*introspect).Interface)
+			break
+		}
+	}
+	if isManager {
+		standard = _AppendInterfaceData(standard, objectManagerIntro.(*introspect).Interface)
+	}
+
+	intro := new(introspect)
+	intro.Interface = make([]interfaceData, len(standard)+len(names))
+	for i, data := range standard {
+		intro.Interface[i] = data
+	}
+	for i, name := range names {
+		intro.Interface[len(standard)+i] = ifaces[name].intro
+	}
+
+	nodes := make([]string, len(children))
+	i = 0
+	for child, _ := range children {
+		nodes[i] = child
+		i++
+	}
+	sort.SortStrings(nodes)
+
+	intro.Node = make([]*introspect, len(nodes))
+	for i, child := range nodes {
+		intro.Node[i] = &introspect{Name: child}
+	}
+	return intro, true
+}
+
+func _AppendInterfaceData(a []interfaceData, b []interfaceData) []interfaceData {
+	c := make([]interfaceData, len(a)+len(b))
+	for i, v := range a {
+		c[i] = v
+	}
+	for i, v := range b {
+		c[len(a)+i] = v
+	}
+	return c
+}
+
+// _ChildNode returns the name of the child of parent on the way to path,
+// or "" if path is not below parent.
+func _ChildNode(parent string, path string) string {
+	prefix := parent + "/"
+	if "/" == parent {
+		prefix = parent
+	}
+	if path == parent || !strings.HasPrefix(path, prefix) {
+		return ""
+	}
+	rest := path[len(prefix):len(path)]
+	if i := strings.Index(rest, "/"); 0 <= i {
+		rest = rest[0:i]
+	}
+	return rest
+}
+
+// _FindMethod returns the exported method of obj called name, or nil.
+func _FindMethod(obj interface{}, name string) *reflect.FuncValue {
+	if obj == nil {
+		return nil
+	}
+	v := reflect.NewValue(obj)
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		if m := t.Method(i); m.Name == name && m.PkgPath == "" {
+			return v.Method(i)
+		}
+	}
+	return nil
+}
+
+// _CallMethodValue converts the arguments of call, invokes fn and builds
+// the reply from its results.
+func _CallMethodValue(call *Message, fn *reflect.FuncValue) *Message {
+	ft := fn.Type().(*reflect.FuncType)
+	args := call.Params.Data()
+	if ft.NumIn() != len(args) {
+		return NewError(call, errorInvalidArgs, "s",
+			fmt.Sprintf("Method '%s' takes %d arguments, got %d", call.Member, ft.NumIn(), len(args)))
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		v, e := _ConvertArg(arg, ft.In(i))
+		if e != nil {
+			return NewError(call, errorInvalidArgs, "s",
+				fmt.Sprintf("Argument %d of '%s': %s", i, call.Member, e.String()))
+		}
+		in[i] = v
+	}
+
+	out := fn.Call(in)
+	if n := len(out); 0 < n && ft.Out(n-1) == osErrorType {
+		if err, _ := out[n-1].Interface().(os.Error); err != nil {
+			return _ErrorToMessage(call, err)
+		}
+		out = out[0 : n-1]
+	}
+
+	reply := NewMethodReturn(call, "")
+	if reply == nil {
+		return nil
+	}
+	for i, v := range out {
+		sig, e := _SignatureOf(ft.Out(i))
+		if e != nil {
+			return NewError(call, errorFailed, "s", e.String())
+		}
+		reply.Sig += sig
+		reply.Params.Push(v.Interface())
+	}
+	return reply
+}
+
+// _ErrorToMessage turns an error returned by an exported method into the
+// ERROR reply to call.
+func _ErrorToMessage(call *Message, err os.Error) *Message {
+	dbusErr, ok := err.(*Error)
+	if !ok {
+		return NewError(call, errorFailed, "s", err.String())
+	}
+
+	reply := NewError(call, dbusErr.Name, "")
+	if reply == nil {
+		return nil
+	}
+	for _, v := range dbusErr.Body {
+		sig, e := _SignatureOf(reflect.Typeof(v))
+		if e != nil {
+			return NewError(call, errorFailed, "s", err.String())
+		}
+		reply.Sig += sig
+		reply.Params.Push(v)
+	}
+	return reply
+}
+
+// _ConvertArg converts a decoded argument to the Go type t. Arrays,
+// dicts and structs arrive as vectors and are rebuilt as slices, maps
+// and structs.
+func _ConvertArg(val interface{}, t reflect.Type) (reflect.Value, os.Error) {
+	if val != nil && reflect.Typeof(val) == t {
+		return reflect.NewValue(val), nil
+	}
+
+	switch typ := t.(type) {
+	case *reflect.InterfaceType:
+		v := reflect.MakeZero(typ).(*reflect.InterfaceValue)
+		if val != nil {
+			v.Set(reflect.NewValue(val))
+		}
+		return v, nil
+
+	case *reflect.SliceType:
+		elems := _ToSlice(val)
+		if elems == nil {
+			break
+		}
+		v := reflect.MakeSlice(typ, len(elems), len(elems))
+		for i, elem := range elems {
+			ev, e := _ConvertArg(elem, typ.Elem())
+			if e != nil {
+				return nil, e
+			}
+			v.Elem(i).SetValue(ev)
+		}
+		return v, nil
+
+	case *reflect.MapType:
+		entries := _ToSlice(val)
+		if entries == nil {
+			break
+		}
+		v := reflect.MakeMap(typ)
+		for _, entry := range entries {
+			kv := _ToSlice(entry)
+			if len(kv) != 2 {
+				return nil, os.NewError("Invalid dict entry")
+			}
+			k, e := _ConvertArg(kv[0], typ.Key())
+			if e != nil {
+				return nil, e
+			}
+			ev, e := _ConvertArg(kv[1], typ.Elem())
+			if e != nil {
+				return nil, e
+			}
+			v.SetElem(k, ev)
+		}
+		return v, nil
+
+	case *reflect.StructType:
+		members := _ToSlice(val)
+		if members == nil || len(members) != typ.NumField() {
+			break
+		}
+		v := reflect.MakeZero(typ).(*reflect.StructValue)
+		for i, member := range members {
+			mv, e := _ConvertArg(member, typ.Field(i).Type)
+			if e != nil {
+				return nil, e
+			}
+			v.Field(i).SetValue(mv)
+		}
+		return v, nil
+	}
+
+	return nil, os.NewError(fmt.Sprintf("Cannot use %v as %v", reflect.Typeof(val), t))
+}
@@ -0,0 +1,277 @@
+package dbus
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPeerConnections returns two connections talking to each other
+// through a pair of testBuses, which answer bus methods such as AddMatch
+// and pass everything else on to the other connection.
+func newPeerConnections() (*Connection, *Connection) {
+	a, aBus := newTestConnection()
+	b, bBus := newTestConnection()
+	aBus.peer = bBus
+	bBus.peer = aBus
+	return a, b
+}
+
+// callPeer calls a method on con's peer and waits for the reply.
+func callPeer(con *Connection, path string, iface string, member string, sig string, args ...) *Message {
+	msg := NewMessage()
+	msg.Type = METHOD_CALL
+	msg.Path = path
+	msg.Iface = iface
+	msg.Member = member
+	msg.Sig = sig
+	msg.Params.AppendVector(_ArgToVector(args))
+
+	var reply *Message
+	con._SendSync(msg, func(r *Message) { reply = r })
+	return reply
+}
+
+type testPoint struct {
+	X, Y int32
+}
+
+type testObject struct{}
+
+func (p *testObject) Add(a int32, b int32) int32 { return a + b }
+
+func (p *testObject) Join(strs []string, sep string) (string, os.Error) {
+	return strings.Join(strs, sep), nil
+}
+
+func (p *testObject) Swap(pt testPoint) testPoint { return testPoint{pt.Y, pt.X} }
+
+func (p *testObject) Keys(m map[string]uint32) []string {
+	keys := make([]string, len(m))
+	i := 0
+	for k, _ := range m {
+		keys[i] = k
+		i++
+	}
+	return keys
+}
+
+func (p *testObject) Fail() os.Error { return os.NewError("it failed") }
+
+func (p *testObject) Raise() os.Error {
+	return &Error{"org.test.Error.Custom", []interface{}{"custom"}}
+}
+
+func (p *testObject) Crash() { panic("crashed") }
+
+// Helper has no D-Bus signature and is not exported.
+func (p *testObject) Helper(ch chan int) {}
+
+func TestExport(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	if e := server.Export(new(testObject), "/org/test", "org.test"); e != nil {
+		t.Fatal("#1 Failed:", e)
+	}
+
+	reply := callPeer(client, "/org/test", "org.test", "Add", "ii", int32(2), int32(3))
+	if METHOD_RETURN != reply.Type || "i" != reply.Sig || 5 != reply.Params.At(0).(int32) {
+		t.Error("#2 Failed:", reply)
+	}
+
+	reply = callPeer(client, "/org/test", "org.test", "Join", "ass", []string{"a", "b"}, "-")
+	if METHOD_RETURN != reply.Type || "s" != reply.Sig || "a-b" != reply.Params.At(0).(string) {
+		t.Error("#3 Failed:", reply)
+	}
+
+	reply = callPeer(client, "/org/test", "", "Swap", "(ii)", testPoint{1, 2})
+	if METHOD_RETURN != reply.Type || "(ii)" != reply.Sig {
+		t.Fatal("#4 Failed:", reply)
+	}
+	if pt := _ToSlice(reply.Params.At(0)); 2 != pt[0].(int32) || 1 != pt[1].(int32) {
+		t.Error("#5 Failed:", pt)
+	}
+
+	reply = callPeer(client, "/org/test", "org.test", "Keys", "a{su}", map[string]uint32{"k": 1})
+	if METHOD_RETURN != reply.Type || "as" != reply.Sig {
+		t.Fatal("#6 Failed:", reply)
+	}
+	if keys := _ToSlice(reply.Params.At(0)); 1 != len(keys) || "k" != keys[0].(string) {
+		t.Error("#7 Failed:", keys)
+	}
+}
+
+type orderObject struct {
+	done chan string
+}
+
+func (p *orderObject) Slow() {
+	time.Sleep(5e7)
+	p.done <- "Slow"
+}
+
+func (p *orderObject) Fast() { p.done <- "Fast" }
+
+func TestExportCallOrder(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	obj := &orderObject{make(chan string, 2)}
+	if e := server.Export(obj, "/org/test", "org.test"); e != nil {
+		t.Fatal("#1 Failed:", e)
+	}
+
+	for _, member := range []string{"Slow", "Fast"} {
+		msg := NewMessage()
+		msg.Type = METHOD_CALL
+		msg.Path = "/org/test"
+		msg.Iface = "org.test"
+		msg.Member = member
+		client._SendAsync(msg, func(*Message) {})
+	}
+
+	if first := <-obj.done; "Slow" != first {
+		t.Error("#2 Failed:", first)
+	}
+	if second := <-obj.done; "Fast" != second {
+		t.Error("#3 Failed:", second)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	server.Export(new(testObject), "/org/test", "org.test")
+
+	tests := []struct {
+		path, iface, member, sig string
+		args                     []interface{}
+		name                     string
+	}{
+		{"/org/test", "org.test", "Fail", "", nil, errorFailed},
+		{"/org/test", "org.test", "Raise", "", nil, "org.test.Error.Custom"},
+		{"/org/test", "org.test", "Missing", "", nil, errorUnknownMethod},
+		{"/org/test", "org.missing", "Add", "", nil, errorUnknownInterface},
+		{"/org/missing", "org.test", "Add", "", nil, errorUnknownObject},
+		{"/org/test", "org.test", "Add", "i", []interface{}{int32(1)}, errorInvalidArgs},
+		{"/org/test", "org.test", "Add", "ss", []interface{}{"a", "b"}, errorInvalidArgs},
+		{"/org/test", "org.test", "Helper", "", nil, errorUnknownMethod},
+		{"/org/test", "org.test", "Crash", "", nil, errorFailed},
+	}
+
+	for i, test := range tests {
+		msg := NewMessage()
+		msg.Type = METHOD_CALL
+		msg.Path = test.path
+		msg.Iface = test.iface
+		msg.Member = test.member
+		msg.Sig = test.sig
+		for _, arg := range test.args {
+			msg.Params.Push(arg)
+		}
+
+		var reply *Message
+		client._SendSync(msg, func(r *Message) { reply = r })
+		if ERROR != reply.Type || test.name != reply.ErrorName {
+			t.Errorf("#%d Failed", i+1)
+		}
+	}
+}
+
+func introspectPeer(t *testing.T, con *Connection, path string) Introspect {
+	reply := callPeer(con, path, "org.freedesktop.DBus.Introspectable", "Introspect", "")
+	if METHOD_RETURN != reply.Type {
+		t.Fatal("Introspect Failed:", path, reply)
+	}
+	intro, e := NewIntrospect(reply.Params.At(0).(string))
+	if e != nil {
+		t.Fatal("Introspect Failed:", path, e)
+	}
+	return intro
+}
+
+func TestExportIntrospect(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	server.Export(new(testObject), "/org/test/a", "org.test")
+	server.Export(new(testObject), "/org/test/b/c", "org.test")
+
+	root := introspectPeer(t, client, "/").(*introspect)
+	if 1 != len(root.Node) || "org" != root.Node[0].Name {
+		t.Error("#1 Failed:", root.Node)
+	}
+
+	intro := introspectPeer(t, client, "/org/test")
+	if nodes := intro.(*introspect).Node; 2 != len(nodes) || "a" != nodes[0].Name || "b" != nodes[1].Name {
+		t.Error("#2 Failed:", nodes)
+	}
+	if intro.GetInterfaceData("org.test") != nil {
+		t.Error("#3 Failed")
+	}
+
+	intro = introspectPeer(t, client, "/org/test/a")
+	if intro.GetInterfaceData("org.freedesktop.DBus.Introspectable") == nil {
+		t.Error("#4 Failed")
+	}
+	iface := intro.GetInterfaceData("org.test")
+	if iface == nil {
+		t.Fatal("#5 Failed")
+	}
+	if meth := iface.GetMethodData("Add"); meth == nil || "ii" != meth.GetInSignature() || "i" != meth.GetOutSignature() {
+		t.Error("#6 Failed")
+	}
+	if meth := iface.GetMethodData("Join"); meth == nil || "ass" != meth.GetInSignature() || "s" != meth.GetOutSignature() {
+		t.Error("#7 Failed")
+	}
+
+	reply := callPeer(client, "/org/missing", "org.freedesktop.DBus.Introspectable", "Introspect", "")
+	if ERROR != reply.Type || errorUnknownObject != reply.ErrorName {
+		t.Error("#8 Failed:", reply)
+	}
+}
+
+func TestExportWithIntrospection(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	desc, _ := NewIntrospect(`
+<node>
+  <interface name="org.test">
+    <method name="Add">
+      <arg name="a" type="i" direction="in"/>
+      <arg name="b" type="i" direction="in"/>
+      <arg name="sum" type="i" direction="out"/>
+    </method>
+    <signal name="Changed">
+      <arg name="value" type="i"/>
+    </signal>
+    <property name="Count" type="u" access="read"/>
+  </interface>
+</node>`)
+	if e := server.ExportWithIntrospection(new(testObject), "/org/test", desc.GetInterfaceData("org.test")); e != nil {
+		t.Fatal("#1 Failed:", e)
+	}
+
+	iface := introspectPeer(t, client, "/org/test").GetInterfaceData("org.test").(interfaceData)
+	if 1 != len(iface.Method) || "sum" != iface.Method[0].Arg[2].Name {
+		t.Error("#2 Failed:", iface.Method)
+	}
+	if 1 != len(iface.Signal) || 1 != len(iface.Property) || "read" != iface.Property[0].Access {
+		t.Error("#3 Failed:", iface.Signal, iface.Property)
+	}
+
+	// methods intro does not describe cannot be called
+	reply := callPeer(client, "/org/test", "org.test", "Join", "ass", []string{"a", "b"}, "-")
+	if ERROR != reply.Type || errorUnknownMethod != reply.ErrorName {
+		t.Error("#4 Failed:", reply)
+	}
+}
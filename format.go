@@ -0,0 +1,263 @@
+package dbus
+
+import (
+	"bytes"
+	"container/vector"
+	"fmt"
+	"strings"
+)
+
+var monitorTypeMap = map[MessageType]string{
+	METHOD_CALL:   "method call",
+	METHOD_RETURN: "method return",
+	ERROR:         "error",
+	SIGNAL:        "signal",
+}
+
+var basicTypeNames = map[byte]string{
+	'y': "byte",
+	'b': "boolean",
+	'n': "int16",
+	'q': "uint16",
+	'i': "int32",
+	'u': "uint32",
+	'x': "int64",
+	't': "uint64",
+	'd': "double",
+	's': "string",
+	'o': "object path",
+	'g': "signature",
+	'h': "file descriptor",
+}
+
+func _OrNull(str string, what string) string {
+	if str == "" {
+		return "(null " + what + ")"
+	}
+	return str
+}
+
+// String formats the message the way dbus-monitor prints it: a header
+// line followed by one typed body value per line.
+func (p *Message) String() string {
+	buff := bytes.NewBuffer([]byte{})
+
+	name, ok := monitorTypeMap[p.Type]
+	if !ok {
+		name = "invalid"
+	}
+	fmt.Fprintf(buff, "%s sender=%s -> destination=%s", name,
+		_OrNull(p.Sender, "sender"), _OrNull(p.Dest, "destination"))
+
+	switch p.Type {
+	case METHOD_CALL, SIGNAL:
+		fmt.Fprintf(buff, " serial=%d path=%s; interface=%s; member=%s",
+			p.serial, p.Path, p.Iface, p.Member)
+	case METHOD_RETURN:
+		fmt.Fprintf(buff, " serial=%d reply_serial=%d", p.serial, p.replySerial)
+	case ERROR:
+		fmt.Fprintf(buff, " error_name=%s reply_serial=%d", p.ErrorName, p.replySerial)
+	}
+	buff.WriteString("\n")
+
+	params := p._TypedParams()
+	sigIdx := 0
+	for i := 0; sigIdx < len(p.Sig) && i < len(params); i++ {
+		sig, e := _GetCompleteType(p.Sig, sigIdx)
+		if e != nil {
+			break
+		}
+		_FormatValue(buff, sig, params[i], 3)
+		sigIdx += len(sig)
+	}
+
+	return buff.String()
+}
+
+// _TypedParams returns the body with its variants as Variant values, so
+// that their contents are printed with their real types. Parse drops the
+// signature of a variant; marshalling the body again recovers it.
+func (p *Message) _TypedParams() []interface{} {
+	body := bytes.NewBuffer([]byte{})
+	if e := _AppendParamsData(body, p.Sig, p.Params); e == nil {
+		if vec, _, e := _Parse(body.Bytes(), p.Sig, 0, true); e == nil {
+			return vec.Data()
+		}
+	}
+	return p.Params.Data()
+}
+
+// ShortString formats the message on a single line, suitable for logs.
+func (p *Message) ShortString() string {
+	svec := new(vector.StringVector)
+
+	if name, ok := typeMap[p.Type]; ok {
+		svec.Push(name)
+	} else {
+		svec.Push("invalid")
+	}
+	if p.Sender != "" {
+		svec.Push("sender=" + p.Sender)
+	}
+	if p.Dest != "" {
+		svec.Push("dest=" + p.Dest)
+	}
+	svec.Push(fmt.Sprintf("serial=%d", p.serial))
+	if p.replySerial != 0 {
+		svec.Push(fmt.Sprintf("reply_serial=%d", p.replySerial))
+	}
+	if p.Path != "" {
+		svec.Push("path=" + p.Path)
+	}
+	if p.Iface != "" {
+		svec.Push("interface=" + p.Iface)
+	}
+	if p.Member != "" {
+		svec.Push("member=" + p.Member)
+	}
+	if p.ErrorName != "" {
+		svec.Push("error_name=" + p.ErrorName)
+	}
+	if p.Sig != "" {
+		svec.Push("sig=" + p.Sig)
+	}
+	if 0 < p.Params.Len() {
+		svec.Push("body=" + _FormatCompact(p.Params))
+	}
+
+	return strings.Join(svec.Data(), " ")
+}
+
+// _ToSlice returns the members of a parsed (*vector.Vector) or
+// hand-built ([]interface{}) container value.
+func _ToSlice(val interface{}) []interface{} {
+	switch v := val.(type) {
+	case *vector.Vector:
+		if v != nil {
+			return v.Data()
+		}
+	case []interface{}:
+		return v
+	}
+	return nil
+}
+
+func _WriteIndent(buff *bytes.Buffer, indent int) {
+	buff.WriteString(strings.Repeat(" ", indent))
+}
+
+func _FormatValue(buff *bytes.Buffer, sig string, val interface{}, indent int) {
+	_WriteIndent(buff, indent)
+	_FormatValueInline(buff, sig, val, indent)
+}
+
+func _FormatValueInline(buff *bytes.Buffer, sig string, val interface{}, indent int) {
+	if len(sig) == 0 {
+		buff.WriteString("\n")
+		return
+	}
+
+	switch sig[0] {
+	case 's', 'o', 'g':
+		fmt.Fprintf(buff, "%s %q\n", basicTypeNames[sig[0]], val)
+
+	case 'v':
+		buff.WriteString("variant ")
+		if v, ok := val.(Variant); ok {
+			_FormatValueInline(buff, v.Sig, v.Value, indent)
+		} else {
+			// a value whose signature could not be recovered
+			_FormatGuessInline(buff, val, indent)
+		}
+
+	case 'a':
+		elemSig := sig[1:len(sig)]
+		buff.WriteString("array [\n")
+		for _, v := range _ToSlice(val) {
+			_FormatValue(buff, elemSig, v, indent+3)
+		}
+		_WriteIndent(buff, indent)
+		buff.WriteString("]\n")
+
+	case '(':
+		buff.WriteString("struct {\n")
+		_FormatMembers(buff, sig[1:len(sig)-1], val, indent+3)
+		_WriteIndent(buff, indent)
+		buff.WriteString("}\n")
+
+	case '{':
+		buff.WriteString("dict entry(\n")
+		_FormatMembers(buff, sig[1:len(sig)-1], val, indent+3)
+		_WriteIndent(buff, indent)
+		buff.WriteString(")\n")
+
+	default:
+		if name, ok := basicTypeNames[sig[0]]; ok {
+			fmt.Fprintf(buff, "%s %v\n", name, val)
+		} else {
+			fmt.Fprintf(buff, "unknown %v\n", val)
+		}
+	}
+}
+
+func _FormatMembers(buff *bytes.Buffer, sig string, val interface{}, indent int) {
+	sigIdx := 0
+	for _, v := range _ToSlice(val) {
+		s, e := _GetCompleteType(sig, sigIdx)
+		if e != nil {
+			return
+		}
+		_FormatValue(buff, s, v, indent)
+		sigIdx += len(s)
+	}
+}
+
+func _FormatGuessInline(buff *bytes.Buffer, val interface{}, indent int) {
+	switch v := val.(type) {
+	case byte:
+		fmt.Fprintf(buff, "byte %d\n", v)
+	case bool:
+		fmt.Fprintf(buff, "boolean %v\n", v)
+	case int16:
+		fmt.Fprintf(buff, "int16 %d\n", v)
+	case uint16:
+		fmt.Fprintf(buff, "uint16 %d\n", v)
+	case int32:
+		fmt.Fprintf(buff, "int32 %d\n", v)
+	case uint32:
+		fmt.Fprintf(buff, "uint32 %d\n", v)
+	case int64:
+		fmt.Fprintf(buff, "int64 %d\n", v)
+	case uint64:
+		fmt.Fprintf(buff, "uint64 %d\n", v)
+	case float64:
+		fmt.Fprintf(buff, "double %v\n", v)
+	case string:
+		fmt.Fprintf(buff, "string %q\n", v)
+	case *vector.Vector, []interface{}:
+		buff.WriteString("array [\n")
+		for _, elem := range _ToSlice(v) {
+			_WriteIndent(buff, indent+3)
+			_FormatGuessInline(buff, elem, indent+3)
+		}
+		_WriteIndent(buff, indent)
+		buff.WriteString("]\n")
+	default:
+		fmt.Fprintf(buff, "%v\n", v)
+	}
+}
+
+// _FormatCompact formats a body value on a single line.
+func _FormatCompact(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case *vector.Vector, []interface{}:
+		svec := new(vector.StringVector)
+		for _, elem := range _ToSlice(v) {
+			svec.Push(_FormatCompact(elem))
+		}
+		return "[" + strings.Join(svec.Data(), ", ") + "]"
+	}
+	return fmt.Sprintf("%v", val)
+}
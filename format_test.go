@@ -0,0 +1,85 @@
+package dbus
+
+import (
+	"container/vector"
+	"testing"
+)
+
+func TestMessageString(t *testing.T) {
+	msg := NewMessage()
+	msg.Type = SIGNAL
+	msg.serial = 2
+	msg.Sender = "org.freedesktop.DBus"
+	msg.Dest = ":1.100"
+	msg.Path = "/org/freedesktop/DBus"
+	msg.Iface = "org.freedesktop.DBus"
+	msg.Member = "NameAcquired"
+	msg.Sig = "sa(su)"
+	msg.Params.Push(":1.100")
+	ary := new(vector.Vector)
+	ary.Push([]interface{}{"test", uint32(1)})
+	msg.Params.Push(ary)
+
+	verifyStr := "signal sender=org.freedesktop.DBus -> destination=:1.100 serial=2 path=/org/freedesktop/DBus; interface=org.freedesktop.DBus; member=NameAcquired\n" +
+		"   string \":1.100\"\n" +
+		"   array [\n" +
+		"      struct {\n" +
+		"         string \"test\"\n" +
+		"         uint32 1\n" +
+		"      }\n" +
+		"   ]\n"
+
+	if str := msg.String(); str != verifyStr {
+		t.Error("#1 Failed\n", str)
+	}
+}
+
+func TestMessageShortString(t *testing.T) {
+	msg := NewMessage()
+	msg.Type = METHOD_RETURN
+	msg.serial = 5
+	msg.replySerial = 3
+	msg.Dest = ":1.7"
+	msg.Sig = "as"
+	ary := new(vector.Vector)
+	ary.Push("a")
+	ary.Push("b")
+	msg.Params.Push(ary)
+
+	verifyStr := "method_return dest=:1.7 serial=5 reply_serial=3 sig=as body=[[\"a\", \"b\"]]"
+	if str := msg.ShortString(); str != verifyStr {
+		t.Error("#1 Failed:", str)
+	}
+}
+
+func TestMessageStringVariant(t *testing.T) {
+	msg := NewMessage()
+	msg.Type = METHOD_RETURN
+	msg.serial = 3
+	msg.replySerial = 2
+	msg.Sender = ":1.7"
+	msg.Dest = ":1.100"
+	msg.Sig = "a{sv}"
+	ary := new(vector.Vector)
+	ary.Push([]interface{}{"Names", Variant{"as", []string{"a"}}})
+	ary.Push([]interface{}{"Path", Variant{"o", "/x"}})
+	msg.Params.Push(ary)
+
+	verifyStr := "method return sender=:1.7 -> destination=:1.100 serial=3 reply_serial=2\n" +
+		"   array [\n" +
+		"      dict entry(\n" +
+		"         string \"Names\"\n" +
+		"         variant array [\n" +
+		"            string \"a\"\n" +
+		"         ]\n" +
+		"      )\n" +
+		"      dict entry(\n" +
+		"         string \"Path\"\n" +
+		"         variant object path \"/x\"\n" +
+		"      )\n" +
+		"   ]\n"
+
+	if str := msg.String(); str != verifyStr {
+		t.Error("#1 Failed\n", str)
+	}
+}
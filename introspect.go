@@ -4,35 +4,51 @@ import (
 	"xml"
 	"os"
 	"bytes"
+	"fmt"
 	"strings"
 )
 
+const introspectDocType = `<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
+"http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
+`
+
 type annotationData struct {
 	Name  string "attr"
 	Value string "attr"
 }
 
 type argData struct {
-	Name      string "attr"
-	Type      string "attr"
-	Direction string "attr"
+	Name       string "attr"
+	Type       string "attr"
+	Direction  string "attr"
+	Annotation []annotationData
 }
 
 type methodData struct {
 	Name       string "attr"
 	Arg        []argData
-	Annotation annotationData
+	Annotation []annotationData
 }
 
 type signalData struct {
-	Name string "attr"
-	Arg  []argData
+	Name       string "attr"
+	Arg        []argData
+	Annotation []annotationData
+}
+
+type propertyData struct {
+	Name       string "attr"
+	Type       string "attr"
+	Access     string "attr"
+	Annotation []annotationData
 }
 
 type interfaceData struct {
-	Name   string "attr"
-	Method []methodData
-	Signal []signalData
+	Name       string "attr"
+	Method     []methodData
+	Signal     []signalData
+	Property   []propertyData
+	Annotation []annotationData
 }
 
 type introspect struct {
@@ -41,24 +57,62 @@ type introspect struct {
 	Node      []*introspect
 }
 
+// Introspect is a parsed introspection document: a node with its
+// interfaces and child nodes. Child nodes are usually empty, naming only
+// a path relative to their parent that can be introspected in turn.
 type Introspect interface {
+	GetName() string
 	GetInterfaceData(name string) InterfaceData
+	GetInterfaces() []InterfaceData
+	GetNodes() []Introspect
 }
 
 type InterfaceData interface {
 	GetMethodData(name string) MethodData
 	GetSignalData(name string) SignalData
+	GetPropertyData(name string) PropertyData
 	GetName() string
+	GetMethods() []MethodData
+	GetSignals() []SignalData
+	GetProperties() []PropertyData
+	GetAnnotations() []AnnotationData
 }
 
 type MethodData interface {
 	GetName() string
 	GetInSignature() string
 	GetOutSignature() string
+	GetArgs() []ArgData
+	GetAnnotations() []AnnotationData
 }
 
 type SignalData interface {
+	GetName() string
+	GetSignature() string
+	GetArgs() []ArgData
+	GetAnnotations() []AnnotationData
+}
+
+type PropertyData interface {
+	GetName() string
 	GetSignature() string
+	GetAccess() string
+	GetAnnotations() []AnnotationData
+}
+
+// ArgData describes an argument of a method or signal. The name is
+// optional; the direction is "in" or "out" for methods and empty for
+// signals.
+type ArgData interface {
+	GetName() string
+	GetSignature() string
+	GetDirection() string
+	GetAnnotations() []AnnotationData
+}
+
+type AnnotationData interface {
+	GetName() string
+	GetValue() string
 }
 
 func NewIntrospect(xmlIntro string) (Introspect, os.Error) {
@@ -72,6 +126,8 @@ func NewIntrospect(xmlIntro string) (Introspect, os.Error) {
 	return intro, nil
 }
 
+func (p introspect) GetName() string { return p.Name }
+
 func (p introspect) GetInterfaceData(name string) InterfaceData {
 	for _, v := range p.Interface {
 		if v.Name == name {
@@ -81,6 +137,22 @@ func (p introspect) GetInterfaceData(name string) InterfaceData {
 	return nil
 }
 
+func (p introspect) GetInterfaces() []InterfaceData {
+	ifaces := make([]InterfaceData, len(p.Interface))
+	for i, v := range p.Interface {
+		ifaces[i] = v
+	}
+	return ifaces
+}
+
+func (p introspect) GetNodes() []Introspect {
+	nodes := make([]Introspect, len(p.Node))
+	for i, v := range p.Node {
+		nodes[i] = v
+	}
+	return nodes
+}
+
 func (p interfaceData) GetMethodData(name string) MethodData {
 	for _, v := range p.Method {
 		if v.GetName() == name {
@@ -99,8 +171,45 @@ func (p interfaceData) GetSignalData(name string) SignalData {
 	return nil
 }
 
+func (p interfaceData) GetPropertyData(name string) PropertyData {
+	for _, v := range p.Property {
+		if v.GetName() == name {
+			return v
+		}
+	}
+	return nil
+}
+
 func (p interfaceData) GetName() string { return p.Name }
 
+func (p interfaceData) GetMethods() []MethodData {
+	methods := make([]MethodData, len(p.Method))
+	for i, v := range p.Method {
+		methods[i] = v
+	}
+	return methods
+}
+
+func (p interfaceData) GetSignals() []SignalData {
+	signals := make([]SignalData, len(p.Signal))
+	for i, v := range p.Signal {
+		signals[i] = v
+	}
+	return signals
+}
+
+func (p interfaceData) GetProperties() []PropertyData {
+	props := make([]PropertyData, len(p.Property))
+	for i, v := range p.Property {
+		props[i] = v
+	}
+	return props
+}
+
+func (p interfaceData) GetAnnotations() []AnnotationData {
+	return _Annotations(p.Annotation)
+}
+
 func (p methodData) GetInSignature() (sig string) {
 	for _, v := range p.Arg {
 		if strings.ToUpper(v.Direction) == "IN" {
@@ -121,6 +230,12 @@ func (p methodData) GetOutSignature() (sig string) {
 
 func (p methodData) GetName() string { return p.Name }
 
+func (p methodData) GetArgs() []ArgData { return _Args(p.Arg) }
+
+func (p methodData) GetAnnotations() []AnnotationData {
+	return _Annotations(p.Annotation)
+}
+
 func (p signalData) GetSignature() (sig string) {
 	for _, v := range p.Arg {
 		sig += v.Type
@@ -129,3 +244,186 @@ func (p signalData) GetSignature() (sig string) {
 }
 
 func (p signalData) GetName() string { return p.Name }
+
+func (p signalData) GetArgs() []ArgData { return _Args(p.Arg) }
+
+func (p signalData) GetAnnotations() []AnnotationData {
+	return _Annotations(p.Annotation)
+}
+
+func (p propertyData) GetName() string { return p.Name }
+
+func (p propertyData) GetSignature() string { return p.Type }
+
+func (p propertyData) GetAccess() string { return p.Access }
+
+func (p propertyData) GetAnnotations() []AnnotationData {
+	return _Annotations(p.Annotation)
+}
+
+func (p argData) GetName() string { return p.Name }
+
+func (p argData) GetSignature() string { return p.Type }
+
+func (p argData) GetDirection() string { return p.Direction }
+
+func (p argData) GetAnnotations() []AnnotationData {
+	return _Annotations(p.Annotation)
+}
+
+func (p annotationData) GetName() string { return p.Name }
+
+func (p annotationData) GetValue() string { return p.Value }
+
+func _Args(args []argData) []ArgData {
+	ret := make([]ArgData, len(args))
+	for i, v := range args {
+		ret[i] = v
+	}
+	return ret
+}
+
+func _Annotations(annotations []annotationData) []AnnotationData {
+	ret := make([]AnnotationData, len(annotations))
+	for i, v := range annotations {
+		ret[i] = v
+	}
+	return ret
+}
+
+// _AnnotationValue returns the value of the annotation name, or def.
+func _AnnotationValue(annotations []annotationData, name string, def string) string {
+	for _, v := range annotations {
+		if v.Name == name {
+			return v.Value
+		}
+	}
+	return def
+}
+
+// _XML renders p as an introspection document.
+func (p *introspect) _XML() string {
+	buff := bytes.NewBuffer([]byte{})
+	buff.WriteString(introspectDocType)
+	p._WriteXML(buff, "")
+	return buff.String()
+}
+
+func (p *introspect) _WriteXML(buff *bytes.Buffer, indent string) {
+	if len(p.Interface) == 0 && len(p.Node) == 0 && p.Name != "" {
+		fmt.Fprintf(buff, "%s<node name=\"%s\"/>\n", indent, _EscapeXML(p.Name))
+		return
+	}
+
+	if p.Name != "" {
+		fmt.Fprintf(buff, "%s<node name=\"%s\">\n", indent, _EscapeXML(p.Name))
+	} else {
+		fmt.Fprintf(buff, "%s<node>\n", indent)
+	}
+	for _, v := range p.Interface {
+		v._WriteXML(buff, indent+"  ")
+	}
+	for _, v := range p.Node {
+		v._WriteXML(buff, indent+"  ")
+	}
+	fmt.Fprintf(buff, "%s</node>\n", indent)
+}
+
+func (p interfaceData) _WriteXML(buff *bytes.Buffer, indent string) {
+	fmt.Fprintf(buff, "%s<interface name=\"%s\">\n", indent, _EscapeXML(p.Name))
+	for _, v := range p.Method {
+		v._WriteXML(buff, indent+"  ")
+	}
+	for _, v := range p.Signal {
+		v._WriteXML(buff, indent+"  ")
+	}
+	for _, v := range p.Property {
+		v._WriteXML(buff, indent+"  ")
+	}
+	for _, v := range p.Annotation {
+		v._WriteXML(buff, indent+"  ")
+	}
+	fmt.Fprintf(buff, "%s</interface>\n", indent)
+}
+
+func (p methodData) _WriteXML(buff *bytes.Buffer, indent string) {
+	fmt.Fprintf(buff, "%s<method name=\"%s\">\n", indent, _EscapeXML(p.Name))
+	for _, v := range p.Arg {
+		v._WriteXML(buff, indent+"  ")
+	}
+	for _, v := range p.Annotation {
+		v._WriteXML(buff, indent+"  ")
+	}
+	fmt.Fprintf(buff, "%s</method>\n", indent)
+}
+
+func (p signalData) _WriteXML(buff *bytes.Buffer, indent string) {
+	fmt.Fprintf(buff, "%s<signal name=\"%s\">\n", indent, _EscapeXML(p.Name))
+	for _, v := range p.Arg {
+		v._WriteXML(buff, indent+"  ")
+	}
+	for _, v := range p.Annotation {
+		v._WriteXML(buff, indent+"  ")
+	}
+	fmt.Fprintf(buff, "%s</signal>\n", indent)
+}
+
+func (p propertyData) _WriteXML(buff *bytes.Buffer, indent string) {
+	fmt.Fprintf(buff, "%s<property name=\"%s\" type=\"%s\" access=\"%s\"",
+		indent, _EscapeXML(p.Name), _EscapeXML(p.Type), _EscapeXML(p.Access))
+	if len(p.Annotation) == 0 {
+		buff.WriteString("/>\n")
+		return
+	}
+	buff.WriteString(">\n")
+	for _, v := range p.Annotation {
+		v._WriteXML(buff, indent+"  ")
+	}
+	fmt.Fprintf(buff, "%s</property>\n", indent)
+}
+
+func (p argData) _WriteXML(buff *bytes.Buffer, indent string) {
+	buff.WriteString(indent + "<arg")
+	if p.Name != "" {
+		fmt.Fprintf(buff, " name=\"%s\"", _EscapeXML(p.Name))
+	}
+	fmt.Fprintf(buff, " type=\"%s\"", _EscapeXML(p.Type))
+	if p.Direction != "" {
+		fmt.Fprintf(buff, " direction=\"%s\"", _EscapeXML(p.Direction))
+	}
+	if len(p.Annotation) == 0 {
+		buff.WriteString("/>\n")
+		return
+	}
+	buff.WriteString(">\n")
+	for _, v := range p.Annotation {
+		v._WriteXML(buff, indent+"  ")
+	}
+	fmt.Fprintf(buff, "%s</arg>\n", indent)
+}
+
+func (p annotationData) _WriteXML(buff *bytes.Buffer, indent string) {
+	fmt.Fprintf(buff, "%s<annotation name=\"%s\" value=\"%s\"/>\n",
+		indent, _EscapeXML(p.Name), _EscapeXML(p.Value))
+}
+
+func _EscapeXML(str string) string {
+	buff := bytes.NewBuffer([]byte{})
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '&':
+			buff.WriteString("&amp;")
+		case '<':
+			buff.WriteString("&lt;")
+		case '>':
+			buff.WriteString("&gt;")
+		case '"':
+			buff.WriteString("&quot;")
+		case '\'':
+			buff.WriteString("&apos;")
+		default:
+			buff.WriteByte(str[i])
+		}
+	}
+	return buff.String()
+}
@@ -76,3 +76,114 @@ func TestIntrospect(t *testing.T) {
 	}
 
 }
+
+func TestIntrospectXML(t *testing.T) {
+	intro, _ := NewIntrospect(introStr)
+	xmlStr := intro.(*introspect)._XML()
+
+	again, e := NewIntrospect(xmlStr)
+	if e != nil {
+		t.Fatalf("reparse failed: %v\n%s", e, xmlStr)
+	}
+
+	intf := again.GetInterfaceData("org.freedesktop.SampleInterface")
+	if intf == nil {
+		t.Fatal("interface lost")
+	}
+	if meth := intf.GetMethodData("Frobate"); meth == nil || meth.GetInSignature() != "i" || meth.GetOutSignature() != "sa{us}" {
+		t.Error("method lost")
+	}
+	if signal := intf.GetSignalData("Changed"); signal == nil || signal.GetSignature() != "b" {
+		t.Error("signal lost")
+	}
+
+	data := intf.(interfaceData)
+	if len(data.Property) != 1 || data.Property[0].Name != "Bar" || data.Property[0].Access != "readwrite" {
+		t.Errorf("property lost: %v", data.Property)
+	}
+	if len(data.Method[0].Annotation) != 1 || data.Method[0].Annotation[0].Name != "org.freedesktop.DBus.Deprecated" {
+		t.Error("annotation lost")
+	}
+	if nodes := again.(*introspect).Node; len(nodes) != 2 || nodes[0].Name != "child_of_sample_object" {
+		t.Errorf("nodes lost: %v", nodes)
+	}
+}
+
+var annotatedStr = `
+<node>
+  <interface name="org.test.Annotated">
+    <method name="Old">
+      <arg name="value" type="s" direction="in">
+        <annotation name="org.test.Arg" value="1"/>
+      </arg>
+      <annotation name="org.freedesktop.DBus.Deprecated" value="true"/>
+      <annotation name="org.freedesktop.DBus.Method.NoReply" value="true"/>
+    </method>
+    <signal name="Tick">
+      <arg type="u"/>
+      <annotation name="org.test.Signal" value="2"/>
+    </signal>
+    <property name="Count" type="u" access="read"/>
+    <annotation name="org.freedesktop.DBus.Property.EmitsChangedSignal" value="invalidates"/>
+  </interface>
+  <node name="child"/>
+</node>`
+
+func TestIntrospectModel(t *testing.T) {
+	first, e := NewIntrospect(annotatedStr)
+	if e != nil {
+		t.Fatal(e)
+	}
+	// everything survives rendering and parsing again
+	intro, e := NewIntrospect(first.(*introspect)._XML())
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if ifaces := intro.GetInterfaces(); len(ifaces) != 1 || ifaces[0].GetName() != "org.test.Annotated" {
+		t.Fatalf("#1 Failed: %v", ifaces)
+	}
+	if nodes := intro.GetNodes(); len(nodes) != 1 || nodes[0].GetName() != "child" {
+		t.Errorf("#2 Failed: %v", nodes)
+	}
+
+	iface := intro.GetInterfaces()[0]
+	if a := iface.GetAnnotations(); len(a) != 1 || a[0].GetValue() != "invalidates" {
+		t.Errorf("#3 Failed: %v", a)
+	}
+	if props := iface.GetProperties(); len(props) != 1 || props[0].GetSignature() != "u" || props[0].GetAccess() != "read" {
+		t.Errorf("#4 Failed: %v", props)
+	}
+
+	methods := iface.GetMethods()
+	if len(methods) != 1 {
+		t.Fatalf("#5 Failed: %v", methods)
+	}
+	if a := methods[0].GetAnnotations(); len(a) != 2 || a[1].GetName() != "org.freedesktop.DBus.Method.NoReply" {
+		t.Errorf("#6 Failed: %v", a)
+	}
+	args := methods[0].GetArgs()
+	if len(args) != 1 || args[0].GetName() != "value" || args[0].GetDirection() != "in" {
+		t.Fatalf("#7 Failed: %v", args)
+	}
+	if a := args[0].GetAnnotations(); len(a) != 1 || a[0].GetName() != "org.test.Arg" || a[0].GetValue() != "1" {
+		t.Errorf("#8 Failed: %v", a)
+	}
+
+	signals := iface.GetSignals()
+	if len(signals) != 1 || signals[0].GetName() != "Tick" || signals[0].GetSignature() != "u" {
+		t.Fatalf("#9 Failed: %v", signals)
+	}
+	if a := signals[0].GetAnnotations(); len(a) != 1 || a[0].GetValue() != "2" {
+		t.Errorf("#10 Failed: %v", a)
+	}
+	if args := signals[0].GetArgs(); len(args) != 1 || args[0].GetName() != "" || args[0].GetDirection() != "" {
+		t.Errorf("#11 Failed: %v", args)
+	}
+}
+
+func TestEscapeXML(t *testing.T) {
+	if s := _EscapeXML(`a<b>&"c'`); s != "a&lt;b&gt;&amp;&quot;c&apos;" {
+		t.Error(s)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 	"container/vector"
 	"fmt"
+	"math"
+	"reflect"
 )
 
 func _Align(length int, index int) int {
@@ -53,16 +55,33 @@ func _AppendInt32(buff *bytes.Buffer, i int32) {
 	binary.Write(buff, binary.LittleEndian, i)
 }
 
+// _AppendArray writes the length of an array, the padding to the
+// alignment of its elements and the elements written by proc. The
+// padding is not counted in the length, even if the array is empty.
 func _AppendArray(buff *bytes.Buffer, align int, proc func(b *bytes.Buffer)) {
 	_AppendAlign(4, buff)
-	_AppendAlign(align, buff)
+	start := buff.Len()
 	b := bytes.NewBuffer(buff.Bytes())
 	b.Write(strings.Bytes("ABCD")) // "ABCD" will be replaced with array-size.
+	_AppendAlign(align, b)
 	pos1 := b.Len()
 	proc(b)
 	pos2 := b.Len()
 	binary.Write(buff, binary.LittleEndian, int32(pos2-pos1))
-	buff.Write(b.Bytes()[pos1:pos2])
+	buff.Write(b.Bytes()[start+4 : pos2])
+}
+
+// _AlignOf returns the alignment of the type starting with code.
+func _AlignOf(code byte) int {
+	switch code {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 's', 'o', 'a':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 1 // y, g, v
 }
 
 func _AppendValue(buff *bytes.Buffer, sig string, val interface{}) (sigOffset int, e os.Error) {
@@ -89,44 +108,258 @@ func _AppendValue(buff *bytes.Buffer, sig string, val interface{}) (sigOffset in
 		_AppendInt32(buff, val.(int32))
 		sigOffset = 1
 
+	case 'b': // boolean
+		if val.(bool) {
+			_AppendUint32(buff, 1)
+		} else {
+			_AppendUint32(buff, 0)
+		}
+		sigOffset = 1
+
+	case 'n': // int16
+		_AppendAlign(2, buff)
+		binary.Write(buff, binary.LittleEndian, val.(int16))
+		sigOffset = 1
+
+	case 'q': // uint16
+		_AppendAlign(2, buff)
+		binary.Write(buff, binary.LittleEndian, val.(uint16))
+		sigOffset = 1
+
+	case 'x': // int64
+		_AppendAlign(8, buff)
+		binary.Write(buff, binary.LittleEndian, val.(int64))
+		sigOffset = 1
+
+	case 't': // uint64
+		_AppendAlign(8, buff)
+		binary.Write(buff, binary.LittleEndian, val.(uint64))
+		sigOffset = 1
+
+	case 'd': // double
+		_AppendAlign(8, buff)
+		binary.Write(buff, binary.LittleEndian, math.Float64bits(val.(float64)))
+		sigOffset = 1
+
+	case 'o': // object path
+		_AppendString(buff, val.(string))
+		sigOffset = 1
+
+	case 'g': // signature
+		_AppendSignature(buff, val.(string))
+		sigOffset = 1
+
 	case 'a': // ary
-		sigBlock, _ := _GetSigBlock(sig, 1)
-		_AppendArray(buff, 1, func(b *bytes.Buffer) {
-			if vec, ok := val.(*vector.Vector); ok && vec != nil {
-				for v := range vec.Iter() {
-					_AppendValue(b, sigBlock, v)
+		sigBlock, se := _GetCompleteType(sig, 1)
+		if se != nil {
+			return 0, se
+		}
+		_AppendArray(buff, _AlignOf(sigBlock[0]), func(b *bytes.Buffer) {
+			for _, v := range _ArrayElements(val) {
+				if _, ee := _AppendValue(b, sigBlock, v); ee != nil && e == nil {
+					e = ee
 				}
 			}
 		})
 		sigOffset = 1 + len(sigBlock)
 
-	case '(': // struct FIXME: nested struct not support
+	case '(': // struct
 		_AppendAlign(8, buff)
 		structSig, _ := _GetStructSig(sig, 0)
-		for i, s := range structSig {
-			_AppendValue(buff, string(s), val.([]interface{})[i])
-		}
+		e = _AppendMembers(buff, structSig, _StructMembers(val))
 		sigOffset = 2 + len(structSig)
 
+	case 'v': // variant
+		vsig, vval, ve := _VariantOf(val)
+		if ve != nil {
+			return 0, ve
+		}
+		_AppendSignature(buff, vsig)
+		_, e = _AppendValue(buff, vsig, vval)
+		sigOffset = 1
+
 	case '{':
 		_AppendAlign(8, buff)
 		dictSig, _ := _GetDictSig(sig, 0)
-		for i, s := range dictSig {
-			_AppendValue(buff, string(s), val.([]interface{})[i])
-		}
+		e = _AppendMembers(buff, dictSig, _StructMembers(val))
 		sigOffset = 2 + len(dictSig)
+
+	default:
+		e = os.NewError("Unsupported Signature")
 	}
 
 	return
 }
 
-func _AppendParamsData(buff *bytes.Buffer, sig string, params *vector.Vector) {
+func _AppendMembers(buff *bytes.Buffer, sig string, members []interface{}) os.Error {
+	sigIdx := 0
+	for _, v := range members {
+		if len(sig) <= sigIdx {
+			return nil
+		}
+		offset, e := _AppendValue(buff, sig[sigIdx:len(sig)], v)
+		if e != nil {
+			return e
+		}
+		sigIdx += offset
+	}
+	return nil
+}
+
+// _ArrayElements returns the elements of an array value, which may be a
+// *vector.Vector, a Go slice, or a Go map (as key/value dict entries).
+func _ArrayElements(val interface{}) []interface{} {
+	switch v := val.(type) {
+	case *vector.Vector:
+		if v != nil {
+			return v.Data()
+		}
+		return nil
+	case []interface{}:
+		return v
+	}
+
+	switch v := reflect.NewValue(val).(type) {
+	case *reflect.SliceValue:
+		elems := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elems[i] = v.Elem(i).Interface()
+		}
+		return elems
+	case *reflect.ArrayValue:
+		elems := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elems[i] = v.Elem(i).Interface()
+		}
+		return elems
+	case *reflect.MapValue:
+		keys := v.Keys()
+		elems := make([]interface{}, len(keys))
+		for i, k := range keys {
+			elems[i] = []interface{}{k.Interface(), v.Elem(k).Interface()}
+		}
+		return elems
+	}
+	return nil
+}
+
+// _StructMembers returns the members of a struct or dict entry value,
+// which may be a *vector.Vector, an []interface{} or a Go struct.
+func _StructMembers(val interface{}) []interface{} {
+	if members := _ToSlice(val); members != nil {
+		return members
+	}
+	if v, ok := reflect.Indirect(reflect.NewValue(val)).(*reflect.StructValue); ok {
+		members := make([]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			members[i] = v.Field(i).Interface()
+		}
+		return members
+	}
+	return nil
+}
+
+func _AppendParamsData(buff *bytes.Buffer, sig string, params *vector.Vector) os.Error {
 	sigOffset := 0
 	prmsOffset := 0
 	for ; sigOffset < len(sig); prmsOffset++ {
-		offset, _ := _AppendValue(buff, sig[sigOffset:len(sig)], params.At(prmsOffset))
+		if params.Len() <= prmsOffset {
+			return os.NewError("Too few parameters for signature")
+		}
+		offset, e := _AppendValue(buff, sig[sigOffset:len(sig)], params.At(prmsOffset))
+		if e != nil {
+			return e
+		}
 		sigOffset += offset
 	}
+	return nil
+}
+
+// A Variant is a value together with its D-Bus signature, for passing
+// variants whose signature cannot be derived from the Go type, such as
+// parsed containers. Received variants are unwrapped to their value.
+type Variant struct {
+	Sig   string
+	Value interface{}
+}
+
+// _VariantOf returns the signature and value to marshal for a variant.
+func _VariantOf(val interface{}) (string, interface{}, os.Error) {
+	switch v := val.(type) {
+	case Variant:
+		return v.Sig, v.Value, nil
+	case *Variant:
+		return v.Sig, v.Value, nil
+	case nil:
+		return "", nil, os.NewError("Cannot marshal nil as a variant")
+	}
+	sig, e := _SignatureOf(reflect.Typeof(val))
+	return sig, val, e
+}
+
+// _SignatureOf returns the D-Bus signature of values of the Go type t.
+func _SignatureOf(t reflect.Type) (string, os.Error) {
+	switch typ := t.(type) {
+	case *reflect.Uint8Type:
+		return "y", nil
+	case *reflect.BoolType:
+		return "b", nil
+	case *reflect.Int16Type:
+		return "n", nil
+	case *reflect.Uint16Type:
+		return "q", nil
+	case *reflect.Int32Type:
+		return "i", nil
+	case *reflect.Uint32Type:
+		return "u", nil
+	case *reflect.Int64Type:
+		return "x", nil
+	case *reflect.Uint64Type:
+		return "t", nil
+	case *reflect.Float64Type:
+		return "d", nil
+	case *reflect.StringType:
+		return "s", nil
+	case *reflect.InterfaceType:
+		return "v", nil
+	case *reflect.PtrType:
+		if _, ok := typ.Elem().(*reflect.StructType); ok {
+			return _SignatureOf(typ.Elem())
+		}
+	case *reflect.SliceType:
+		elem, e := _SignatureOf(typ.Elem())
+		if e != nil {
+			return "", e
+		}
+		return "a" + elem, nil
+	case *reflect.ArrayType:
+		elem, e := _SignatureOf(typ.Elem())
+		if e != nil {
+			return "", e
+		}
+		return "a" + elem, nil
+	case *reflect.MapType:
+		key, e := _SignatureOf(typ.Key())
+		if e != nil {
+			return "", e
+		}
+		elem, e := _SignatureOf(typ.Elem())
+		if e != nil {
+			return "", e
+		}
+		return "a{" + key + elem + "}", nil
+	case *reflect.StructType:
+		sig := "("
+		for i := 0; i < typ.NumField(); i++ {
+			field, e := _SignatureOf(typ.Field(i).Type)
+			if e != nil {
+				return "", e
+			}
+			sig += field
+		}
+		return sig + ")", nil
+	}
+	return "", os.NewError(fmt.Sprintf("No D-Bus type for %v", t))
 }
 
 func _GetByte(buff []byte, index int) (byte, os.Error) {
@@ -184,6 +417,18 @@ func _GetUint32(buff []byte, index int) (uint32, os.Error) {
 	return u, nil
 }
 
+func _GetUint64(buff []byte, index int) (uint64, os.Error) {
+	if len(buff) <= index+8-1 {
+		return 0, os.NewError("index error")
+	}
+	var t uint64
+	e := binary.Read(bytes.NewBuffer(buff[index:len(buff)]), binary.LittleEndian, &t)
+	if e != nil {
+		return 0, e
+	}
+	return t, nil
+}
+
 func _GetBoolean(buff []byte, index int) (bool, os.Error) {
 	if len(buff) <= index+4-1 {
 		return false, os.NewError("index error")
@@ -265,17 +510,48 @@ func _GetSigBlock(sig string, index int) (string, os.Error) {
 	return sig[index : index+1], nil
 }
 
+// _GetCompleteType returns the single complete type starting at index,
+// including the element type of an array.
+func _GetCompleteType(sig string, index int) (string, os.Error) {
+	if len(sig) <= index {
+		return "", os.NewError("index error")
+	}
+	if 'a' == sig[index] {
+		elem, e := _GetCompleteType(sig, index+1)
+		if e != nil {
+			return "", e
+		}
+		return "a" + elem, nil
+	}
+	return _GetSigBlock(sig, index)
+}
+
 func _GetVariant(buff []byte, index int) (valvec *vector.Vector, retidx int, e os.Error) {
-	retidx = index
-	sigSize := int(buff[retidx])
-	retidx++
-	sig := string(buff[retidx : retidx+sigSize])
-	valvec, retidx, e = Parse(buff, sig, retidx+sigSize+1)
+	_, valvec, retidx, e = _ParseVariant(buff, index, false)
 	return
 }
 
+// _ParseVariant returns the signature and the contents of the variant at
+// index.
+func _ParseVariant(buff []byte, index int, keepVariants bool) (sig string, valvec *vector.Vector, retidx int, e os.Error) {
+	sigSize, e := _GetByte(buff, index)
+	if e != nil {
+		return
+	}
+	if sig, e = _GetString(buff, index+1, int(sigSize)); e != nil {
+		return
+	}
+	valvec, retidx, e = _Parse(buff, sig, index+1+int(sigSize)+1, keepVariants)
+	return
+}
 
 func Parse(buff []byte, sig string, index int) (vec *vector.Vector, bufIdx int, err os.Error) {
+	return _Parse(buff, sig, index, false)
+}
+
+// _Parse is Parse, but with keepVariants a variant is returned as a
+// Variant holding its signature rather than as its bare value.
+func _Parse(buff []byte, sig string, index int, keepVariants bool) (vec *vector.Vector, bufIdx int, err os.Error) {
 	vec = new(vector.Vector)
 	bufIdx = index
 	for sigIdx := 0; sigIdx < len(sig); {
@@ -338,6 +614,39 @@ func Parse(buff []byte, sig string, index int) (vec *vector.Vector, bufIdx int,
 			bufIdx += 4
 			sigIdx++
 
+		case 'i': // int32
+			bufIdx = _Align(4, bufIdx)
+
+			i, e := _GetInt32(buff, bufIdx)
+			if e != nil {
+				err = e
+				return
+			}
+
+			vec.Push(i)
+			bufIdx += 4
+			sigIdx++
+
+		case 'x', 't', 'd': // int64, uint64, double
+			bufIdx = _Align(8, bufIdx)
+
+			t, e := _GetUint64(buff, bufIdx)
+			if e != nil {
+				err = e
+				return
+			}
+
+			switch sig[sigIdx] {
+			case 'x':
+				vec.Push(int64(t))
+			case 't':
+				vec.Push(t)
+			case 'd':
+				vec.Push(math.Float64frombits(t))
+			}
+			bufIdx += 8
+			sigIdx++
+
 		case 's', 'o': // string, object
 			bufIdx = _Align(4, bufIdx)
 
@@ -381,16 +690,18 @@ func Parse(buff []byte, sig string, index int) (vec *vector.Vector, bufIdx int,
 				return
 			}
 
-			sigBlock, e := _GetSigBlock(sig, sigIdx+1)
+			sigBlock, e := _GetCompleteType(sig, sigIdx+1)
 			if e != nil {
 				err = e
 				return
 			}
 
-			aryIdx := startIdx + 4
+			// the padding to the first element is not part of the size
+			aryIdx := _Align(_AlignOf(sigBlock[0]), startIdx+4)
+			aryEnd := aryIdx + int(arySize)
 			aryVec := new(vector.Vector)
-			for aryIdx < (startIdx+4)+int(arySize) {
-				retvec, retidx, e := Parse(buff, sigBlock, aryIdx)
+			for aryIdx < aryEnd {
+				retvec, retidx, e := _Parse(buff, sigBlock, aryIdx, keepVariants)
 				if e != nil {
 					err = e
 					return
@@ -411,7 +722,7 @@ func Parse(buff []byte, sig string, index int) (vec *vector.Vector, bufIdx int,
 				return
 			}
 
-			retvec, retidx, e := Parse(buff, stSig, idx)
+			retvec, retidx, e := _Parse(buff, stSig, idx, keepVariants)
 			if e != nil {
 				err = e
 				return
@@ -429,7 +740,7 @@ func Parse(buff []byte, sig string, index int) (vec *vector.Vector, bufIdx int,
 				return
 			}
 
-			retvec, retidx, e := Parse(buff, stSig, idx)
+			retvec, retidx, e := _Parse(buff, stSig, idx, keepVariants)
 			if e != nil {
 				err = e
 				return
@@ -440,7 +751,7 @@ func Parse(buff []byte, sig string, index int) (vec *vector.Vector, bufIdx int,
 			vec.Push(retvec)
 
 		case 'v': // variant
-			val, idx, e := _GetVariant(buff, bufIdx)
+			vsig, val, idx, e := _ParseVariant(buff, bufIdx, keepVariants)
 			if e != nil {
 				err = e
 				return
@@ -448,11 +759,14 @@ func Parse(buff []byte, sig string, index int) (vec *vector.Vector, bufIdx int,
 
 			bufIdx = idx
 			sigIdx++
-			vec.AppendVector(val)
+			if keepVariants && 1 == val.Len() {
+				vec.Push(Variant{vsig, val.At(0)})
+			} else {
+				vec.AppendVector(val)
+			}
 
 		default:
-			fmt.Println(sig[sigIdx])
-			return nil, index, os.NewError("unknown type")
+			return nil, index, os.NewError(fmt.Sprintf("unknown type '%c'", sig[sigIdx]))
 		}
 	}
 	return
@@ -128,7 +128,7 @@ func TestAppendValue(t *testing.T) {
 	vec.Push([]interface{}{"test2", uint32(2)})
 	vec.Push([]interface{}{"test3", uint32(3)})
 	_AppendValue(buff, "a(su)", vec)
-	if !bytes.Equal(strings.Bytes("\x34\x00\x00\x00\x00\x00\x00\x00\x05\x00\x00\x00test1\x00\x00\x00\x01\x00\x00\x00\x05\x00\x00\x00test2\x00\x00\x00\x02\x00\x00\x00\x05\x00\x00\x00test3\x00\x00\x00\x03\x00\x00\x00"), buff.Bytes()) {
+	if !bytes.Equal(strings.Bytes("\x30\x00\x00\x00\x00\x00\x00\x00\x05\x00\x00\x00test1\x00\x00\x00\x01\x00\x00\x00\x05\x00\x00\x00test2\x00\x00\x00\x02\x00\x00\x00\x05\x00\x00\x00test3\x00\x00\x00\x03\x00\x00\x00"), buff.Bytes()) {
 		t.Error("#2 Failed", buff.Bytes())
 	}
 }
@@ -255,7 +255,7 @@ func TestParse(t *testing.T) {
 		t.Error("#3-4 Failed:")
 	}
 
-	ret, _, e := Parse(strings.Bytes("\x22\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x04\x00\x00\x00true\x00\x00\x00\x00\x00\x00\x00\x00\x05\x00\x00\x00false\x00"), "a(bs)", 0)
+	ret, _, e := Parse(strings.Bytes("\x1e\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x04\x00\x00\x00true\x00\x00\x00\x00\x00\x00\x00\x00\x05\x00\x00\x00false\x00"), "a(bs)", 0)
 	if e != nil {
 		t.Error(e.String())
 	}
@@ -281,6 +281,55 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestAppendArrayPadding(t *testing.T) {
+	buff := bytes.NewBuffer([]byte{})
+	_AppendValue(buff, "a{sv}", map[string]interface{}{})
+	if !bytes.Equal(strings.Bytes("\x00\x00\x00\x00\x00\x00\x00\x00"), buff.Bytes()) {
+		t.Error("#1 Failed", buff.Bytes())
+	}
+
+	buff.Reset()
+	_AppendValue(buff, "a{sv}", map[string]interface{}{"a": uint32(1)})
+	if !bytes.Equal(strings.Bytes("\x10\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00a\x00\x01u\x00\x00\x01\x00\x00\x00"), buff.Bytes()) {
+		t.Error("#2 Failed", buff.Bytes())
+	}
+
+	// no padding when the length already ends aligned
+	buff.Reset()
+	_AppendValue(buff, "i", int32(7))
+	_AppendValue(buff, "ax", []int64{})
+	if !bytes.Equal(strings.Bytes("\x07\x00\x00\x00\x00\x00\x00\x00"), buff.Bytes()) {
+		t.Error("#3 Failed", buff.Bytes())
+	}
+
+	buff.Reset()
+	_AppendValue(buff, "aas", []interface{}{[]string{"a"}, []string{}})
+	if !bytes.Equal(strings.Bytes("\x10\x00\x00\x00\x06\x00\x00\x00\x01\x00\x00\x00a\x00\x00\x00\x00\x00\x00\x00"), buff.Bytes()) {
+		t.Error("#4 Failed", buff.Bytes())
+	}
+}
+
+func TestParseArrayPadding(t *testing.T) {
+	ret, idx, e := Parse(strings.Bytes("\x00\x00\x00\x00\x00\x00\x00\x00\x01"), "a{sv}y", 0)
+	if e != nil || 0 != vecRef(ret, 0).(*vector.Vector).Len() || byte(1) != vecRef(ret, 1).(byte) || 9 != idx {
+		t.Error("#1 Failed", idx, e)
+	}
+
+	ret, _, e = Parse(strings.Bytes("\x10\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00a\x00\x01u\x00\x00\x01\x00\x00\x00"), "a{sv}", 0)
+	if e != nil || "a" != vecRef(ret, 0, 0, 0).(string) || uint32(1) != vecRef(ret, 0, 0, 1).(uint32) {
+		t.Error("#2 Failed", e)
+	}
+
+	ret, _, e = Parse(strings.Bytes("\x10\x00\x00\x00\x06\x00\x00\x00\x01\x00\x00\x00a\x00\x00\x00\x00\x00\x00\x00\x02"), "aasy", 0)
+	if e != nil || "a" != vecRef(ret, 0, 0, 0).(string) || 0 != vecRef(ret, 0, 1).(*vector.Vector).Len() || byte(2) != vecRef(ret, 1).(byte) {
+		t.Error("#3 Failed", e)
+	}
+
+	if _, _, e = Parse(strings.Bytes("\x00"), "z", 0); e == nil {
+		t.Error("#4 Failed")
+	}
+}
+
 func TestGetVariant(t *testing.T) {
 	val, index, _ := _GetVariant(strings.Bytes("\x00\x00\x01s\x00\x00\x00\x00\x04\x00\x00\x00test\x00"), 2)
 	str, ok := val.At(0).(string)
@@ -1,10 +1,11 @@
-
 package dbus
 
 import (
+	"bytes"
 	"container/vector"
 	"fmt"
-	"reflect"
+	"os"
+	"strconv"
 	"strings"
 )
 
@@ -15,35 +16,293 @@ var typeMap = map[MessageType] string{
   SIGNAL : "signal",
   ERROR : "error",
 }
-	
+
+// maxMatchArgs is the number of argN keys the spec allows (arg0..arg63).
+const maxMatchArgs = 64
+
 type MatchRule struct{
 	Type string
+	Sender string
 	Interface string
 	Member string
 	Path string
+	PathNamespace string
+	Destination string
+	Args map[int]string     // argN: string argument N equals the value
+	ArgPaths map[int]string // argNpath: path argument N is or contains the value
+	Arg0Namespace string
+	Eavesdrop bool
+}
+
+func _QuoteMatchValue(str string) string{
+	// an apostrophe cannot appear inside quotes; close, escape, reopen
+	return "'" + strings.Join(strings.Split(str, "'", 0), "'\\''") + "'"
 }
 
 func(p *MatchRule) _ToString() string{
 	svec := new(vector.StringVector)
 
-	v := reflect.Indirect(reflect.NewValue(p)).(*reflect.StructValue)
-	t := v.Type().(*reflect.StructType)
-	for i:=0; i<v.NumField(); i++{
-		str, ok := v.Field(i).Interface().(string)
-		if ok && "" != str{
-			svec.Push(fmt.Sprintf("%s='%s'", strings.ToLower(t.Field(i).Name), str))
-		}	
+	push := func(key, value string){
+		if "" != value{
+			svec.Push(key + "=" + _QuoteMatchValue(value))
+		}
+	}
+
+	push("type", p.Type)
+	push("sender", p.Sender)
+	push("interface", p.Interface)
+	push("member", p.Member)
+	push("path", p.Path)
+	push("path_namespace", p.PathNamespace)
+	push("destination", p.Destination)
+	// an empty argN value is a condition of its own, only unset keys are left out
+	for i:=0; i<maxMatchArgs; i++{
+		if value, ok := p.Args[i]; ok{
+			svec.Push(fmt.Sprintf("arg%d=", i) + _QuoteMatchValue(value))
+		}
 	}
-	
+	for i:=0; i<maxMatchArgs; i++{
+		if value, ok := p.ArgPaths[i]; ok{
+			svec.Push(fmt.Sprintf("arg%dpath=", i) + _QuoteMatchValue(value))
+		}
+	}
+	push("arg0namespace", p.Arg0Namespace)
+	if p.Eavesdrop{
+		push("eavesdrop", "true")
+	}
+
 	return strings.Join(svec.Data(),",")
 }
 
-func(p *MatchRule) _Match(msg *Message) bool{
+// _Check rejects argument indexes the bus does not know, which _ToString
+// would silently leave out.
+func(p *MatchRule) _Check() os.Error{
+	for i, _ := range p.Args{
+		if i < 0 || maxMatchArgs <= i{
+			return os.NewError(fmt.Sprintf("match rule: invalid key arg%d", i))
+		}
+	}
+	for i, _ := range p.ArgPaths{
+		if i < 0 || maxMatchArgs <= i{
+			return os.NewError(fmt.Sprintf("match rule: invalid key arg%dpath", i))
+		}
+	}
+	return nil
+}
+
+// _Match reports whether msg satisfies the rule. owner is the unique
+// name currently owning a well-known Sender, as the bus would resolve it,
+// or "" if it is unknown or has no owner.
+func(p *MatchRule) _Match(msg *Message, owner string) bool{
 	if p.Type != "" && p.Type != typeMap[msg.Type]{ return false}
+	if p.Sender != "" && p.Sender != msg.Sender && (owner == "" || owner != msg.Sender){ return false}
 	if p.Interface != "" && p.Interface != msg.Iface { return false}
 	if p.Member != "" && p.Member != msg.Member { return false}
 	if p.Path != "" && p.Path != msg.Path { return false}
+	if p.PathNamespace != "" && !_InPathNamespace(msg.Path, p.PathNamespace){ return false}
+	if p.Destination != "" && p.Destination != msg.Dest { return false}
+
+	for i, value := range p.Args{
+		arg, ok := _MatchArg(msg, i, "s")
+		if !ok || arg != value { return false}
+	}
+	for i, value := range p.ArgPaths{
+		arg, ok := _MatchArg(msg, i, "so")
+		if !ok || !_ArgPathMatch(arg, value) { return false}
+	}
+	if p.Arg0Namespace != ""{
+		arg, ok := _MatchArg(msg, 0, "s")
+		if !ok || !(arg == p.Arg0Namespace || strings.HasPrefix(arg, p.Arg0Namespace+".")){ return false}
+	}
 	return true
 }
 
-	
\ No newline at end of file
+// _IsWellKnownName reports whether a rule's sender must be resolved to
+// its owner. The bus itself only ever sends as org.freedesktop.DBus.
+func _IsWellKnownName(name string) bool{
+	return name != "" && !strings.HasPrefix(name, ":") && name != "org.freedesktop.DBus"
+}
+
+// _MatchArg returns argument i of msg if its type is one of types; argN
+// keys only ever match strings, argNpath also object paths.
+func _MatchArg(msg *Message, i int, types string) (string, bool){
+	idx := 0
+	for n := 0; idx < len(msg.Sig); n++ {
+		t, e := _GetCompleteType(msg.Sig, idx)
+		if e != nil { return "", false}
+		if n == i {
+			if len(t) != 1 || strings.Index(types, t) < 0 { return "", false}
+			return _StringArg(msg, i)
+		}
+		idx += len(t)
+	}
+	return "", false
+}
+
+func _StringArg(msg *Message, i int) (string, bool){
+	if i < 0 || msg.Params.Len() <= i { return "", false}
+	str, ok := msg.Params.At(i).(string)
+	return str, ok
+}
+
+func _InPathNamespace(path string, namespace string) bool{
+	if path == namespace || "/" == namespace { return true}
+	return strings.HasPrefix(path, namespace+"/")
+}
+
+// _ArgPathMatch implements argNpath: equal paths match, and a value
+// ending in '/' matches everything below it (in either direction).
+func _ArgPathMatch(arg string, value string) bool{
+	if arg == value { return true}
+	if strings.HasSuffix(value, "/") && strings.HasPrefix(arg, value){ return true}
+	if strings.HasSuffix(arg, "/") && strings.HasPrefix(value, arg){ return true}
+	return false
+}
+
+// ParseMatchRule parses a match rule in the bus's string syntax, such as
+// "type='signal',sender='org.foo',arg0namespace='com.bar'". Unknown,
+// repeated and empty keys are errors, as is a trailing comma.
+func ParseMatchRule(str string) (*MatchRule, os.Error){
+	mr := new(MatchRule)
+	seen := make(map[string]bool)
+
+	for i:=0; i<len(str); {
+		for i<len(str) && (' ' == str[i] || '\t' == str[i]) { i++}
+		if i == len(str) { break}
+
+		eq := strings.Index(str[i:len(str)], "=")
+		if eq < 0 {
+			return nil, os.NewError(fmt.Sprintf("match rule: missing '=' after %q", str[i:len(str)]))
+		}
+		key := strings.TrimSpace(str[i:i+eq])
+		if key == "" {
+			return nil, os.NewError(fmt.Sprintf("match rule: missing key before %q", str[i:len(str)]))
+		}
+		i += eq + 1
+
+		value, n, e := _ParseMatchValue(str[i:len(str)])
+		if e != nil { return nil, e}
+		i += n
+		if i < len(str) {
+			i++ // skip ','
+			if strings.TrimSpace(str[i:len(str)]) == "" {
+				return nil, os.NewError("match rule: trailing ','")
+			}
+		}
+
+		if seen[key] {
+			return nil, os.NewError(fmt.Sprintf("match rule: duplicate key %q", key))
+		}
+		seen[key] = true
+
+		if e = mr._SetKey(key, value); e != nil { return nil, e}
+	}
+
+	if mr.Path != "" && mr.PathNamespace != "" {
+		return nil, os.NewError("match rule: path and path_namespace are mutually exclusive")
+	}
+	return mr, nil
+}
+
+// _ParseMatchValue reads a value up to the next unquoted comma and returns
+// it unquoted together with the number of bytes consumed. Inside quotes
+// every character is literal; outside, \' stands for an apostrophe.
+func _ParseMatchValue(str string) (string, int, os.Error){
+	buff := bytes.NewBuffer([]byte{})
+	inQuote := false
+
+	i := 0
+	for ; i<len(str); i++ {
+		c := str[i]
+		if inQuote {
+			if '\'' == c {
+				inQuote = false
+			} else {
+				buff.WriteByte(c)
+			}
+			continue
+		}
+
+		switch c {
+		case ',':
+			return buff.String(), i, nil
+		case '\'':
+			inQuote = true
+		case '\\':
+			if i+1 < len(str) && '\'' == str[i+1] {
+				buff.WriteByte('\'')
+				i++
+			} else {
+				buff.WriteByte(c)
+			}
+		default:
+			buff.WriteByte(c)
+		}
+	}
+
+	if inQuote {
+		return "", i, os.NewError("match rule: unterminated quote")
+	}
+	return buff.String(), i, nil
+}
+
+func(p *MatchRule) _SetKey(key string, value string) os.Error{
+	switch key {
+	case "type":
+		for t, name := range typeMap {
+			if name == value && INVALID != t {
+				p.Type = value
+				return nil
+			}
+		}
+		return os.NewError(fmt.Sprintf("match rule: invalid type %q", value))
+	case "sender":
+		p.Sender = value
+	case "interface":
+		p.Interface = value
+	case "member":
+		p.Member = value
+	case "path":
+		p.Path = value
+	case "path_namespace":
+		p.PathNamespace = value
+	case "destination":
+		p.Destination = value
+	case "arg0namespace":
+		p.Arg0Namespace = value
+	case "eavesdrop":
+		switch value {
+		case "true":
+			p.Eavesdrop = true
+		case "false":
+			p.Eavesdrop = false
+		default:
+			return os.NewError(fmt.Sprintf("match rule: invalid eavesdrop value %q", value))
+		}
+	default:
+		return p._SetArgKey(key, value)
+	}
+	return nil
+}
+
+// _SetArgKey handles the argN and argNpath keys.
+func(p *MatchRule) _SetArgKey(key string, value string) os.Error{
+	unknown := os.NewError(fmt.Sprintf("match rule: unknown key %q", key))
+	if !strings.HasPrefix(key, "arg") { return unknown}
+
+	num := key[3:len(key)]
+	isPath := strings.HasSuffix(num, "path")
+	if isPath { num = num[0:len(num)-4]}
+
+	n, e := strconv.Atoi(num)
+	if e != nil || n < 0 || maxMatchArgs <= n || num != strconv.Itoa(n) { return unknown}
+
+	if isPath {
+		if p.ArgPaths == nil { p.ArgPaths = make(map[int]string)}
+		p.ArgPaths[n] = value
+	} else {
+		if p.Args == nil { p.Args = make(map[int]string)}
+		p.Args[n] = value
+	}
+	return nil
+}
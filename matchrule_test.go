@@ -16,3 +16,121 @@ func TestToString(t *testing.T){
 
 	if mr._ToString() != verifyStr { t.Error("#1 Failed")}
 }
+
+func TestToStringFull(t *testing.T){
+	verifyStr := "type='signal',sender='org.foo',path_namespace='/org/foo',destination=':1.5',arg0='it'\\''s',arg2='x',arg1path='/a/',arg0namespace='com.bar',eavesdrop='true'"
+
+	mr := MatchRule{
+		Type: "signal",
+		Sender: "org.foo",
+		PathNamespace: "/org/foo",
+		Destination: ":1.5",
+		Args: map[int]string{0: "it's", 2: "x"},
+		ArgPaths: map[int]string{1: "/a/"},
+		Arg0Namespace: "com.bar",
+		Eavesdrop: true}
+
+	if str := mr._ToString(); str != verifyStr { t.Error("#1 Failed:", str)}
+}
+
+func TestToStringEmptyArg(t *testing.T){
+	mr := MatchRule{Type: "signal", Args: map[int]string{0: ""}, ArgPaths: map[int]string{1: ""}}
+	if str := mr._ToString(); str != "type='signal',arg0='',arg1path=''" { t.Error("#1 Failed:", str)}
+
+	parsed, e := ParseMatchRule("arg0=''")
+	if e != nil { t.Fatal("#2 Failed:", e)}
+	if value, ok := parsed.Args[0]; !ok || value != "" { t.Error("#3 Failed")}
+}
+
+func newMatchTestMessage(sig string, args ...) *Message{
+	msg := NewMessage()
+	msg.Type = SIGNAL
+	msg.Sender = ":1.7"
+	msg.Dest = ":1.5"
+	msg.Path = "/org/foo/bar"
+	msg.Iface = "org.foo"
+	msg.Member = "Changed"
+	msg.Sig = sig
+	msg.Params.AppendVector(_ArgToVector(args))
+	return msg
+}
+
+func TestMatch(t *testing.T){
+	msg := newMatchTestMessage("sou", "com.bar.Baz", "/a/b/c", uint32(1))
+
+	if !(&MatchRule{Type: "signal", Destination: ":1.5"})._Match(msg, "") { t.Error("#1 Failed")}
+	if (&MatchRule{Type: "method_call"})._Match(msg, "") { t.Error("#2 Failed")}
+	if !(&MatchRule{Sender: ":1.7"})._Match(msg, "") { t.Error("#3-1 Failed")}
+	if (&MatchRule{Sender: ":1.8"})._Match(msg, "") { t.Error("#3-2 Failed")}
+	if (&MatchRule{Sender: "org.foo"})._Match(msg, "") { t.Error("#3-3 Failed")}
+	if !(&MatchRule{Sender: "org.foo"})._Match(msg, ":1.7") { t.Error("#3-4 Failed")}
+	if (&MatchRule{Sender: "org.foo"})._Match(msg, ":1.8") { t.Error("#3-5 Failed")}
+
+	if !(&MatchRule{PathNamespace: "/org/foo"})._Match(msg, "") { t.Error("#4-1 Failed")}
+	if !(&MatchRule{PathNamespace: "/"})._Match(msg, "") { t.Error("#4-2 Failed")}
+	if (&MatchRule{PathNamespace: "/org/fo"})._Match(msg, "") { t.Error("#4-3 Failed")}
+
+	if !(&MatchRule{Args: map[int]string{0: "com.bar.Baz"}})._Match(msg, "") { t.Error("#5-1 Failed")}
+	if (&MatchRule{Args: map[int]string{2: "1"}})._Match(msg, "") { t.Error("#5-2 Failed")}
+	if (&MatchRule{Args: map[int]string{5: "x"}})._Match(msg, "") { t.Error("#5-3 Failed")}
+
+	if !(&MatchRule{ArgPaths: map[int]string{1: "/a/b/"}})._Match(msg, "") { t.Error("#6-1 Failed")}
+	if !(&MatchRule{ArgPaths: map[int]string{1: "/a/b/c"}})._Match(msg, "") { t.Error("#6-2 Failed")}
+	if (&MatchRule{ArgPaths: map[int]string{1: "/a/b"}})._Match(msg, "") { t.Error("#6-3 Failed")}
+	msg2 := newMatchTestMessage("so", "", "/a/")
+	if !(&MatchRule{ArgPaths: map[int]string{1: "/a/b/c"}})._Match(msg2, "") { t.Error("#6-4 Failed")}
+
+	if !(&MatchRule{Arg0Namespace: "com.bar"})._Match(msg, "") { t.Error("#7-1 Failed")}
+	if (&MatchRule{Arg0Namespace: "com.ba"})._Match(msg, "") { t.Error("#7-2 Failed")}
+
+	// argN only matches strings, argNpath strings and object paths
+	msg3 := newMatchTestMessage("ogs", "/a/b/c", "s", "/a/b/c")
+	if (&MatchRule{Args: map[int]string{0: "/a/b/c"}})._Match(msg3, "") { t.Error("#8-1 Failed")}
+	if (&MatchRule{Args: map[int]string{1: "s"}})._Match(msg3, "") { t.Error("#8-2 Failed")}
+	if !(&MatchRule{Args: map[int]string{2: "/a/b/c"}})._Match(msg3, "") { t.Error("#8-3 Failed")}
+	if !(&MatchRule{ArgPaths: map[int]string{0: "/a/"}})._Match(msg3, "") { t.Error("#8-4 Failed")}
+	if (&MatchRule{Arg0Namespace: "/a"})._Match(msg3, "") { t.Error("#8-5 Failed")}
+}
+
+func TestParseMatchRule(t *testing.T){
+	rules := []string{
+		"type='signal',interface='org.freedesktop.DBus',member='Foo',path='/bar/foo'",
+		"type='signal',sender='org.foo',arg0namespace='com.bar'",
+		"type='method_call',path_namespace='/org',destination=':1.5',arg0='it'\\''s',arg63='x',arg1path='/a/',eavesdrop='true'",
+		"",
+	}
+	for i, str := range rules {
+		mr, e := ParseMatchRule(str)
+		if e != nil {
+			t.Error("#1 Failed:", i, e)
+			continue
+		}
+		if mr._ToString() != str { t.Error("#2 Failed:", i, mr._ToString())}
+	}
+
+	mr, e := ParseMatchRule("type=signal, arg0=a\\'b ,member='x,y'")
+	if e != nil { t.Fatal("#3 Failed:", e)}
+	if "signal" != mr.Type || "a'b " != mr.Args[0] || "x,y" != mr.Member {
+		t.Error("#4 Failed:", mr._ToString())
+	}
+}
+
+func TestParseMatchRuleErrors(t *testing.T){
+	invalid := []string{
+		"type='signal',type='signal'", // duplicate key
+		"typo='signal'",               // unknown key
+		"type='sig'",                  // unknown type
+		"arg64='x'",                   // argN out of range
+		"arg01='x'",
+		"member='Foo",                 // unterminated quote
+		"member",                      // missing '='
+		"eavesdrop='yes'",
+		"path='/a',path_namespace='/a'",
+		"type='signal',",              // empty pair
+		"type='signal',,member='x'",
+		"='x'",
+	}
+	for i, str := range invalid {
+		if _, e := ParseMatchRule(str); e == nil { t.Error("#1 Failed:", i, str)}
+	}
+}
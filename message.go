@@ -39,7 +39,7 @@ type Message struct {
 	serial      int
 	replySerial uint32
 	ErrorName   string
-	//	Sender;
+	Sender      string
 }
 
 var serialMutex sync.Mutex
@@ -66,6 +66,45 @@ func NewMessage() *Message {
 	return msg
 }
 
+// NewMethodReturn builds the reply to a METHOD_CALL. It returns nil if
+// the caller set NO_REPLY_EXPECTED; Connection.Send ignores nil messages.
+func NewMethodReturn(call *Message, sig string, args ...) *Message {
+	if call.Flags&NO_REPLY_EXPECTED != 0 {
+		return nil
+	}
+
+	msg := NewMessage()
+	msg.Type = METHOD_RETURN
+	msg.replySerial = uint32(call.serial)
+	msg.Dest = call.Sender
+	msg.Sig = sig
+	msg.Params.AppendVector(_ArgToVector(args))
+
+	return msg
+}
+
+// NewError builds an ERROR reply to a METHOD_CALL. It returns nil if the
+// caller set NO_REPLY_EXPECTED.
+func NewError(call *Message, name string, sig string, args ...) *Message {
+	if call.Flags&NO_REPLY_EXPECTED != 0 {
+		return nil
+	}
+
+	msg := NewMessage()
+	msg.Type = ERROR
+	msg.ErrorName = name
+	msg.replySerial = uint32(call.serial)
+	msg.Dest = call.Sender
+	msg.Sig = sig
+	msg.Params.AppendVector(_ArgToVector(args))
+
+	return msg
+}
+
+func (p *Message) Serial() uint32 { return uint32(p.serial) }
+
+func (p *Message) ReplySerial() uint32 { return p.replySerial }
+
 func (p *Message) _BufferToMessage(buff []byte) (int, os.Error) {
 	vec, bufIdx, e := Parse(buff, "yyyyuua(yv)", 0)
 	if e != nil {
@@ -96,14 +135,20 @@ func (p *Message) _BufferToMessage(buff []byte) (int, os.Error) {
 		case 6:
 			p.Dest = val.(string)
 		case 7:
-			// FIXME
+			p.Sender = val.(string)
 		case 8:
 			p.Sig = val.(string)
 		}
 	}
 	idx := _Align(8, bufIdx)
+	if len(buff) < idx+p.bodyLength {
+		return 0, os.NewError("index error") // body not received yet
+	}
 	if 0 < p.bodyLength {
-		vec, idx, _ = Parse(buff, p.Sig, idx)
+		vec, idx, e = Parse(buff, p.Sig, idx)
+		if e != nil {
+			return 0, e
+		}
 		p.Params.AppendVector(vec)
 	}
 	return idx, nil
@@ -126,11 +171,13 @@ func (p *Message) _Marshal() ([]byte, os.Error) {
 	_AppendByte(buff, byte(p.Protocol))
 
 	tmpBuff := bytes.NewBuffer([]byte{})
-	_AppendParamsData(tmpBuff, p.Sig, p.Params)
+	if e := _AppendParamsData(tmpBuff, p.Sig, p.Params); e != nil {
+		return nil, e
+	}
 	_AppendUint32(buff, uint32(len(tmpBuff.Bytes())))
 	_AppendUint32(buff, uint32(p.serial))
 
-	_AppendArray(buff, 1,
+	_AppendArray(buff, 8,
 		func(b *bytes.Buffer) {
 			if p.Path != "" {
 				_AppendAlign(8, b)
@@ -159,6 +206,15 @@ func (p *Message) _Marshal() ([]byte, os.Error) {
 				_AppendString(b, p.Member)
 			}
 
+			if p.ErrorName != "" {
+				_AppendAlign(8, b)
+				_AppendByte(b, 4) // error name
+				_AppendByte(b, 1) // signature size
+				_AppendByte(b, 's')
+				_AppendByte(b, 0)
+				_AppendString(b, p.ErrorName)
+			}
+
 			if p.replySerial != 0 {
 				_AppendAlign(8, b)
 				_AppendByte(b, 5) // reply serial
@@ -177,6 +233,15 @@ func (p *Message) _Marshal() ([]byte, os.Error) {
 				_AppendString(b, p.Dest)
 			}
 
+			if p.Sender != "" {
+				_AppendAlign(8, b)
+				_AppendByte(b, 7) // sender
+				_AppendByte(b, 1) // signature size
+				_AppendByte(b, 's')
+				_AppendByte(b, 0)
+				_AppendString(b, p.Sender)
+			}
+
 			if p.Sig != "" {
 				_AppendAlign(8, b)
 				_AppendByte(b, 8) // signature
@@ -48,3 +48,58 @@ func TestMarshal(t *testing.T) {
 		t.Error("#1 Failed\n", buff, "\n", strings.Bytes(teststr))
 	}
 }
+
+func TestNewMethodReturn(t *testing.T) {
+	call := NewMessage()
+	call.Type = METHOD_CALL
+	call.Sender = ":1.5"
+	call.Member = "Frobate"
+
+	reply := NewMethodReturn(call, "s", "done")
+	if reply == nil {
+		t.Fatal("#1 Failed")
+	}
+	if METHOD_RETURN != reply.Type {
+		t.Error("#2 Failed :", reply.Type)
+	}
+	if call.Serial() != reply.ReplySerial() {
+		t.Error("#3 Failed :", reply.ReplySerial())
+	}
+	if ":1.5" != reply.Dest {
+		t.Error("#4 Failed :", reply.Dest)
+	}
+	if "done" != reply.Params.At(0).(string) {
+		t.Error("#5 Failed")
+	}
+
+	call.Flags = NO_REPLY_EXPECTED
+	if nil != NewMethodReturn(call, "") {
+		t.Error("#6 Failed")
+	}
+}
+
+func TestNewError(t *testing.T) {
+	call := NewMessage()
+	call.Type = METHOD_CALL
+	call.Sender = ":1.5"
+
+	reply := NewError(call, "org.freedesktop.DBus.Error.Failed", "s", "failed")
+	if ERROR != reply.Type {
+		t.Error("#1 Failed :", reply.Type)
+	}
+
+	buff, _ := reply._Marshal()
+	msg, _, e := _Unmarshal(buff)
+	if nil != e {
+		t.Fatal("#2 Failed")
+	}
+	if "org.freedesktop.DBus.Error.Failed" != msg.ErrorName {
+		t.Error("#3 Failed :", msg.ErrorName)
+	}
+	if call.Serial() != msg.ReplySerial() {
+		t.Error("#4 Failed :", msg.ReplySerial())
+	}
+	if "failed" != msg.Params.At(0).(string) {
+		t.Error("#5 Failed")
+	}
+}
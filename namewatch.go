@@ -0,0 +1,144 @@
+package dbus
+
+import (
+	"os"
+	"sync"
+)
+
+// A NameWatch follows the owner of a bus name, like g_bus_watch_name.
+type NameWatch struct {
+	conn     *Connection
+	name     string
+	appeared func(name string, owner string)
+	vanished func(name string)
+	handle   *SignalHandle
+	ready    chan bool  // closed once the initial owner is known
+	mutex    sync.Mutex // guards the fields below
+	known    bool       // the initial owner has been received
+	owner    string
+	serial   uint32 // serial of the newest bus message applied
+}
+
+// WatchName calls appeared with the unique name of the owner when name
+// gets an owner and vanished when it loses it; a change of owner is
+// reported as both. The first call reports the current state, as given
+// by GetNameOwner. Either callback may be nil; both run on the run loop,
+// as with AddSignalHandler.
+func (p *Connection) WatchName(name string, appeared func(name string, owner string), vanished func(name string)) (*NameWatch, os.Error) {
+	watch := &NameWatch{conn: p, name: name, appeared: appeared, vanished: vanished}
+	watch.ready = make(chan bool)
+
+	// subscribe before asking, so that no change is missed
+	mr := &MatchRule{
+		Type:      "signal",
+		Sender:    "org.freedesktop.DBus",
+		Interface: "org.freedesktop.DBus",
+		Member:    "NameOwnerChanged",
+		Args:      map[int]string{0: name},
+	}
+	handle, e := p.AddSignalHandler(mr, func(msg *Message) { watch._OwnerChanged(msg) })
+	if e != nil {
+		return nil, e
+	}
+	watch.handle = handle
+
+	msg := NewMessage()
+	msg.Type = METHOD_CALL
+	msg.Path = "/org/freedesktop/DBus"
+	msg.Dest = "org.freedesktop.DBus"
+	msg.Iface = "org.freedesktop.DBus"
+	msg.Member = "GetNameOwner"
+	msg.Sig = "s"
+	msg.Params.Push(name)
+
+	if e := p._SendAsync(msg, func(reply *Message) { watch._InitialOwner(reply) }); e != nil {
+		watch.handle.Remove()
+		return nil, e
+	}
+	return watch, nil
+}
+
+// Owner returns the current owner of the name, or "" if it has none or
+// is not known yet.
+func (p *NameWatch) Owner() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.owner
+}
+
+// Stop ends the watch; no callback is called afterwards.
+func (p *NameWatch) Stop() os.Error {
+	p.mutex.Lock()
+	p.appeared = nil
+	p.vanished = nil
+	p.mutex.Unlock()
+
+	return p.handle.Remove()
+}
+
+func (p *NameWatch) _InitialOwner(reply *Message) {
+	serial := reply.Serial()
+	if reply.ErrorName == errorNoReply || reply.ErrorName == errorDisconnected {
+		serial = 0 // synthesized locally; every bus message is newer
+	}
+	owner := ""
+	if reply.Type == METHOD_RETURN {
+		owner, _ = _StringArg(reply, 0)
+	}
+
+	p.mutex.Lock()
+	p.known = true
+	p.mutex.Unlock()
+
+	p._SetOwner(owner, serial, true)
+	close(p.ready)
+}
+
+func (p *NameWatch) _OwnerChanged(msg *Message) {
+	if msg.Params.Len() < 3 {
+		return
+	}
+	owner, _ := _StringArg(msg, 2)
+
+	p.mutex.Lock()
+	known := p.known
+	p.mutex.Unlock()
+
+	// signals received before the reply to GetNameOwner are older than
+	// it and already reflected in it
+	if known {
+		p._SetOwner(owner, msg.Serial(), false)
+	}
+}
+
+// _SetOwner applies the owner reported by a bus message with the given
+// serial, unless a newer message has been applied already.
+func (p *NameWatch) _SetOwner(owner string, serial uint32, initial bool) {
+	p.mutex.Lock()
+	if serial != 0 && serial < p.serial {
+		p.mutex.Unlock()
+		return
+	}
+	if serial != 0 {
+		p.serial = serial
+	}
+	old := p.owner
+	p.owner = owner
+	appeared := p.appeared
+	vanished := p.vanished
+	p.mutex.Unlock()
+
+	if !initial && old == owner {
+		return
+	}
+	if old != "" && vanished != nil {
+		vanished(p.name)
+	}
+	if owner != "" {
+		if appeared != nil {
+			appeared(p.name, owner)
+		}
+	} else if initial && vanished != nil {
+		vanished(p.name)
+	}
+}
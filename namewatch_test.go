@@ -0,0 +1,91 @@
+package dbus
+
+import (
+	"testing"
+)
+
+// nameEvents records the callbacks of a NameWatch as "+owner" and "-".
+func nameEvents() (chan string, func(string, string), func(string)) {
+	events := make(chan string, 10)
+	appeared := func(name string, owner string) { events <- "+" + owner }
+	vanished := func(name string) { events <- "-" }
+	return events, appeared, vanished
+}
+
+func TestWatchName(t *testing.T) {
+	con, bus := newTestConnection()
+	events, appeared, vanished := nameEvents()
+
+	watch, e := con.WatchName("org.test.Service", appeared, vanished)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if ev := <-events; "-" != ev {
+		t.Error("#1 Failed:", ev)
+	}
+
+	bus._EmitNameOwnerChanged("org.test.Service", "", ":1.5")
+	if ev := <-events; "+:1.5" != ev {
+		t.Error("#2 Failed:", ev)
+	}
+	if ":1.5" != watch.Owner() {
+		t.Error("#3 Failed:", watch.Owner())
+	}
+
+	// a replacement is reported as vanished and appeared
+	bus._EmitNameOwnerChanged("org.test.Service", ":1.5", ":1.6")
+	if ev := <-events; "-" != ev {
+		t.Error("#4 Failed:", ev)
+	}
+	if ev := <-events; "+:1.6" != ev {
+		t.Error("#5 Failed:", ev)
+	}
+
+	watch.Stop()
+	bus._EmitNameOwnerChanged("org.test.Service", ":1.6", "")
+	con.ListNames() // round trip
+	if 0 != len(events) {
+		t.Error("#6 Failed:", <-events)
+	}
+}
+
+func TestWatchNameRace(t *testing.T) {
+	con, bus := newTestConnection()
+	bus.owners = map[string]string{"org.test.Service": ":1.5"}
+
+	// the name changes hands while GetNameOwner is in flight; the reply
+	// is newer than the signal and wins
+	var stale *Message
+	bus.onCall = func(call *Message) {
+		if "GetNameOwner" == call.Member {
+			stale = bus._EmitNameOwnerChanged("org.test.Service", ":1.4", ":1.5")
+		}
+	}
+
+	events, appeared, vanished := nameEvents()
+	if _, e := con.WatchName("org.test.Service", appeared, vanished); e != nil {
+		t.Fatal(e)
+	}
+	if ev := <-events; "+:1.5" != ev {
+		t.Error("#1 Failed:", ev)
+	}
+
+	// a signal older than the reply arriving late is ignored
+	late := NewMessage()
+	late.serial = stale.serial
+	late.Type = SIGNAL
+	late.Sender = "org.freedesktop.DBus"
+	late.Path = "/org/freedesktop/DBus"
+	late.Iface = "org.freedesktop.DBus"
+	late.Member = "NameOwnerChanged"
+	late.Sig = "sss"
+	late.Params.Push("org.test.Service")
+	late.Params.Push(":1.5")
+	late.Params.Push("")
+	bus._Send(late)
+
+	con.ListNames() // round trip
+	if 0 != len(events) {
+		t.Error("#2 Failed:", <-events)
+	}
+}
@@ -0,0 +1,363 @@
+package dbus
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const objectManagerXMLIntro = `
+<node>
+  <interface name="org.freedesktop.DBus.ObjectManager">
+    <method name="GetManagedObjects">
+      <arg name="objects" type="a{oa{sa{sv}}}" direction="out"/>
+    </method>
+    <signal name="InterfacesAdded">
+      <arg name="object_path" type="o"/>
+      <arg name="interfaces_and_properties" type="a{sa{sv}}"/>
+    </signal>
+    <signal name="InterfacesRemoved">
+      <arg name="object_path" type="o"/>
+      <arg name="interfaces" type="as"/>
+    </signal>
+  </interface>
+</node>`
+
+const objectManagerInterface = "org.freedesktop.DBus.ObjectManager"
+
+var objectManagerIntro, _ = NewIntrospect(objectManagerXMLIntro)
+
+// ExportObjectManager implements org.freedesktop.DBus.ObjectManager at
+// path for every object exported below it. InterfacesAdded and
+// InterfacesRemoved are emitted as interfaces are exported and
+// unexported there.
+func (p *Connection) ExportObjectManager(path string) os.Error {
+	if !strings.HasPrefix(path, "/") {
+		return os.NewError("Invalid object path")
+	}
+
+	p.exportMutex.Lock()
+	p.managers[path] = true
+	p.exportMutex.Unlock()
+	return nil
+}
+
+// _ManagerOf returns the closest object manager above path, or "".
+func (p *Connection) _ManagerOf(path string) string {
+	p.exportMutex.RLock()
+	defer p.exportMutex.RUnlock()
+
+	manager := ""
+	for m, _ := range p.managers {
+		if _ChildNode(m, path) != "" && len(manager) < len(m) {
+			manager = m
+		}
+	}
+	return manager
+}
+
+// _ManagedObjectsReply answers GetManagedObjects if call.Path is an
+// object manager.
+func (p *Connection) _ManagedObjectsReply(call *Message) (*Message, bool) {
+	p.exportMutex.RLock()
+	defer p.exportMutex.RUnlock()
+
+	if !p.managers[call.Path] {
+		return nil, false
+	}
+
+	objects := make(map[string]map[string]map[string]interface{})
+	for path, ifaces := range p.exports {
+		if _ChildNode(call.Path, path) == "" {
+			continue
+		}
+		object := make(map[string]map[string]interface{})
+		for name, exported := range ifaces {
+			object[name] = exported._PropertyValues()
+		}
+		objects[path] = object
+	}
+	return NewMethodReturn(call, "a{oa{sa{sv}}}", objects), true
+}
+
+func (p *exportedInterface) _PropertyValues() map[string]interface{} {
+	if p.props == nil {
+		return make(map[string]interface{})
+	}
+	return p.props._Values()
+}
+
+func (p *Connection) _EmitInterfacesAdded(path string, iface string) {
+	manager := p._ManagerOf(path)
+	if manager == "" {
+		return
+	}
+
+	values := make(map[string]interface{})
+	p.exportMutex.RLock()
+	if ifaces, ok := p.exports[path]; ok {
+		if exported, ok := ifaces[iface]; ok {
+			values = exported._PropertyValues()
+		}
+	}
+	p.exportMutex.RUnlock()
+
+	msg := NewMessage()
+	msg.Type = SIGNAL
+	msg.Path = manager
+	msg.Iface = objectManagerInterface
+	msg.Member = "InterfacesAdded"
+	msg.Sig = "oa{sa{sv}}"
+	msg.Params.Push(path)
+	msg.Params.Push(map[string]map[string]interface{}{iface: values})
+	p.Send(msg)
+}
+
+func (p *Connection) _EmitInterfacesRemoved(path string, ifaces []string) {
+	manager := p._ManagerOf(path)
+	if manager == "" || len(ifaces) == 0 {
+		return
+	}
+
+	msg := NewMessage()
+	msg.Type = SIGNAL
+	msg.Path = manager
+	msg.Iface = objectManagerInterface
+	msg.Member = "InterfacesRemoved"
+	msg.Sig = "oas"
+	msg.Params.Push(path)
+	msg.Params.Push(ifaces)
+	p.Send(msg)
+}
+
+// An ObjectCache mirrors the objects of a remote ObjectManager: their
+// paths, interfaces and properties. It is filled with GetManagedObjects
+// and kept up to date with InterfacesAdded, InterfacesRemoved and
+// PropertiesChanged.
+type ObjectCache struct {
+	conn    *Connection
+	dest    string
+	path    string
+	mutex   sync.RWMutex // guards objects
+	objects map[string]map[string]map[string]interface{}
+	handles []*SignalHandle
+}
+
+// NewObjectCache starts mirroring the object manager at path on dest.
+func (p *Connection) NewObjectCache(dest string, path string) (*ObjectCache, os.Error) {
+	cache := &ObjectCache{conn: p, dest: dest, path: path}
+	cache.objects = make(map[string]map[string]map[string]interface{})
+
+	// subscribe first; the run loop applies signals and the reply in the
+	// order they arrive, so no change is lost or applied out of order
+	mr := &MatchRule{Type: "signal", Sender: dest, Path: path, Interface: objectManagerInterface}
+	mr.Member = "InterfacesAdded"
+	if e := cache._AddHandler(mr, func(msg *Message) { cache._Added(msg) }); e != nil {
+		return nil, e
+	}
+	mr.Member = "InterfacesRemoved"
+	if e := cache._AddHandler(mr, func(msg *Message) { cache._Removed(msg) }); e != nil {
+		cache.Close()
+		return nil, e
+	}
+	mr = &MatchRule{Type: "signal", Sender: dest, PathNamespace: path, Interface: propertiesInterface, Member: "PropertiesChanged"}
+	if e := cache._AddHandler(mr, func(msg *Message) { cache._Changed(msg) }); e != nil {
+		cache.Close()
+		return nil, e
+	}
+
+	msg := NewMessage()
+	msg.Type = METHOD_CALL
+	msg.Path = path
+	msg.Dest = dest
+	msg.Iface = objectManagerInterface
+	msg.Member = "GetManagedObjects"
+
+	var err os.Error
+	e := p._SendSync(msg, func(reply *Message) {
+		if reply.Type == ERROR {
+			err = &Error{reply.ErrorName, reply.Params.Data()}
+		} else if 0 < reply.Params.Len() {
+			cache._Reset(reply.Params.At(0))
+		}
+	})
+	if e == nil {
+		e = err
+	}
+	if e != nil {
+		cache.Close()
+		return nil, e
+	}
+	return cache, nil
+}
+
+func (p *ObjectCache) _AddHandler(mr *MatchRule, proc func(*Message)) os.Error {
+	handle, e := p.conn.AddSignalHandler(mr, proc)
+	if e != nil {
+		return e
+	}
+	handles := make([]*SignalHandle, len(p.handles)+1)
+	for i, v := range p.handles {
+		handles[i] = v
+	}
+	handles[len(p.handles)] = handle
+	p.handles = handles
+	return nil
+}
+
+// Close stops following the object manager.
+func (p *ObjectCache) Close() {
+	for _, handle := range p.handles {
+		handle.Remove()
+	}
+}
+
+// Paths returns the paths of all objects, sorted.
+func (p *ObjectCache) Paths() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	paths := make([]string, len(p.objects))
+	i := 0
+	for path, _ := range p.objects {
+		paths[i] = path
+		i++
+	}
+	sort.SortStrings(paths)
+	return paths
+}
+
+// Interfaces returns the interfaces of the object at path, sorted.
+func (p *ObjectCache) Interfaces(path string) []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	object := p.objects[path]
+	ifaces := make([]string, len(object))
+	i := 0
+	for name, _ := range object {
+		ifaces[i] = name
+		i++
+	}
+	sort.SortStrings(ifaces)
+	return ifaces
+}
+
+// Properties returns a copy of the properties of iface on the object at
+// path, or nil if the object does not implement it.
+func (p *ObjectCache) Properties(path string, iface string) map[string]interface{} {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	object, ok := p.objects[path]
+	if !ok {
+		return nil
+	}
+	props, ok := object[iface]
+	if !ok {
+		return nil
+	}
+	copied := make(map[string]interface{})
+	for name, value := range props {
+		copied[name] = value
+	}
+	return copied
+}
+
+func (p *ObjectCache) _Reset(val interface{}) {
+	objects := make(map[string]map[string]map[string]interface{})
+	for _, entry := range _ToSlice(val) {
+		kv := _ToSlice(entry)
+		if len(kv) != 2 {
+			continue
+		}
+		if path, ok := kv[0].(string); ok {
+			objects[path] = _InterfaceMap(kv[1])
+		}
+	}
+
+	p.mutex.Lock()
+	p.objects = objects
+	p.mutex.Unlock()
+}
+
+// _InterfaceMap converts a parsed a{sa{sv}} to a map.
+func _InterfaceMap(val interface{}) map[string]map[string]interface{} {
+	ifaces := make(map[string]map[string]interface{})
+	for _, entry := range _ToSlice(val) {
+		if kv := _ToSlice(entry); len(kv) == 2 {
+			if name, ok := kv[0].(string); ok {
+				ifaces[name] = _PropertyMap(kv[1])
+			}
+		}
+	}
+	return ifaces
+}
+
+func (p *ObjectCache) _Added(msg *Message) {
+	path, ok := _StringArg(msg, 0)
+	if !ok || msg.Params.Len() < 2 {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	object, ok := p.objects[path]
+	if !ok {
+		object = make(map[string]map[string]interface{})
+		p.objects[path] = object
+	}
+	for name, props := range _InterfaceMap(msg.Params.At(1)) {
+		object[name] = props
+	}
+}
+
+func (p *ObjectCache) _Removed(msg *Message) {
+	path, ok := _StringArg(msg, 0)
+	if !ok || msg.Params.Len() < 2 {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	object, ok := p.objects[path]
+	if !ok {
+		return
+	}
+	for _, v := range _ToSlice(msg.Params.At(1)) {
+		if name, ok := v.(string); ok {
+			object[name] = nil, false
+		}
+	}
+	if len(object) == 0 {
+		p.objects[path] = nil, false
+	}
+}
+
+func (p *ObjectCache) _Changed(msg *Message) {
+	changed := _ParsePropertiesChanged(msg)
+	if changed == nil {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	object, ok := p.objects[msg.Path]
+	if !ok {
+		return
+	}
+	props, ok := object[changed.Interface]
+	if !ok {
+		return
+	}
+	for name, value := range changed.Changed {
+		props[name] = value
+	}
+	for _, name := range changed.Invalidated {
+		props[name] = nil, false
+	}
+}
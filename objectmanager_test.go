@@ -0,0 +1,101 @@
+package dbus
+
+import (
+	"testing"
+)
+
+// syncPeer makes a round trip to con's peer, so that every message the
+// peer sent before has been dispatched when it returns.
+func syncPeer(con *Connection) {
+	callPeer(con, "/", "org.freedesktop.DBus.Introspectable", "Introspect", "")
+}
+
+func TestObjectManager(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	server.ExportObjectManager("/org/test")
+	desc, _ := NewIntrospect(testStoreXML)
+	store, _ := server.ExportProperties("/org/test/dev1", desc.GetInterfaceData("org.test.Store"),
+		map[string]interface{}{"Name": "dev1", "Level": int32(1)})
+
+	cache, e := client.NewObjectCache("", "/org/test")
+	if e != nil {
+		t.Fatal("#1 Failed:", e)
+	}
+	defer cache.Close()
+
+	if paths := cache.Paths(); 1 != len(paths) || "/org/test/dev1" != paths[0] {
+		t.Fatal("#2 Failed:", paths)
+	}
+	if props := cache.Properties("/org/test/dev1", "org.test.Store"); "dev1" != props["Name"].(string) {
+		t.Error("#3 Failed:", props)
+	}
+
+	server.Export(new(testObject), "/org/test/dev2", "org.test")
+	syncPeer(client)
+	if paths := cache.Paths(); 2 != len(paths) || "/org/test/dev2" != paths[1] {
+		t.Error("#4 Failed:", paths)
+	}
+	if ifaces := cache.Interfaces("/org/test/dev2"); 1 != len(ifaces) || "org.test" != ifaces[0] {
+		t.Error("#5 Failed:", ifaces)
+	}
+
+	store.Set("Name", "renamed")
+	syncPeer(client)
+	if props := cache.Properties("/org/test/dev1", "org.test.Store"); "renamed" != props["Name"].(string) {
+		t.Error("#6 Failed:", props)
+	}
+
+	server.Unexport("/org/test/dev1", "")
+	syncPeer(client)
+	if paths := cache.Paths(); 1 != len(paths) || "/org/test/dev2" != paths[0] {
+		t.Error("#7 Failed:", paths)
+	}
+}
+
+func TestObjectManagerIntrospect(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	server.ExportObjectManager("/org/test")
+	server.Export(new(testObject), "/org/test/dev", "org.test")
+
+	if introspectPeer(t, client, "/org/test").GetInterfaceData(objectManagerInterface) == nil {
+		t.Error("#1 Failed")
+	}
+
+	// /org/test/dev is not a manager
+	if _, e := client.NewObjectCache("", "/org/test/dev"); e == nil {
+		t.Error("#2 Failed")
+	}
+}
+
+func TestUnexport(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	server.Export(new(testObject), "/org/test", "org.test")
+	server.Export(new(testObject), "/org/test", "org.test.Other")
+
+	if e := server.Unexport("/org/test", "org.test"); e != nil {
+		t.Error("#1 Failed:", e)
+	}
+	reply := callPeer(client, "/org/test", "org.test", "Add", "ii", int32(1), int32(2))
+	if ERROR != reply.Type || errorUnknownInterface != reply.ErrorName {
+		t.Error("#2 Failed:", reply)
+	}
+
+	server.Unexport("/org/test", "org.test.Other")
+	reply = callPeer(client, "/org/test", "org.test.Other", "Add", "ii", int32(1), int32(2))
+	if ERROR != reply.Type || errorUnknownObject != reply.ErrorName {
+		t.Error("#3 Failed:", reply)
+	}
+
+	if e := server.Unexport("/org/test", ""); e == nil {
+		t.Error("#4 Failed")
+	}
+}
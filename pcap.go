@@ -0,0 +1,154 @@
+package dbus
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DLT_DBUS = 231
+
+	pcapMagic        = 0xa1b2c3d4
+	pcapVersionMajor = 2
+	pcapVersionMinor = 4
+	pcapSnapLen      = 134217728 // maximum message size allowed by the spec
+)
+
+var ErrPcapFormat = os.NewError("InvalidPcapFormat")
+
+type pcapHeader struct {
+	Magic        uint32
+	VersionMajor uint16
+	VersionMinor uint16
+	ThisZone     int32
+	SigFigs      uint32
+	SnapLen      uint32
+	Network      uint32
+}
+
+type pcapRecordHeader struct {
+	TsSec   uint32
+	TsUsec  uint32
+	InclLen uint32
+	OrigLen uint32
+}
+
+// A PcapWriter records marshalled messages in pcap format using the
+// DLT_DBUS link type, so captures can be opened with Wireshark.
+type PcapWriter struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+func NewPcapWriter(w io.Writer) (*PcapWriter, os.Error) {
+	hdr := pcapHeader{
+		Magic:        pcapMagic,
+		VersionMajor: pcapVersionMajor,
+		VersionMinor: pcapVersionMinor,
+		SnapLen:      pcapSnapLen,
+		Network:      DLT_DBUS,
+	}
+	if e := binary.Write(w, binary.LittleEndian, &hdr); e != nil {
+		return nil, e
+	}
+	return &PcapWriter{w: w}, nil
+}
+
+// WriteMessage marshals msg and appends it as one packet.
+func (p *PcapWriter) WriteMessage(msg *Message) os.Error {
+	buff, e := msg._Marshal()
+	if e != nil {
+		return e
+	}
+	return p._WritePacket(buff)
+}
+
+func (p *PcapWriter) _WritePacket(buff []byte) os.Error {
+	now := time.Nanoseconds()
+	hdr := pcapRecordHeader{
+		TsSec:   uint32(now / 1e9),
+		TsUsec:  uint32(now % 1e9 / 1e3),
+		InclLen: uint32(len(buff)),
+		OrigLen: uint32(len(buff)),
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if e := binary.Write(p.w, binary.LittleEndian, &hdr); e != nil {
+		return e
+	}
+	_, e := p.w.Write(buff)
+	return e
+}
+
+// ReadPcap reads a DLT_DBUS capture and unmarshals every packet.
+func ReadPcap(r io.Reader) ([]*Message, os.Error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	var hdr pcapHeader
+	if e := binary.Read(r, order, &hdr); e != nil {
+		return nil, e
+	}
+	if hdr.Magic != pcapMagic {
+		order = binary.BigEndian
+		if e := _SwapPcapHeader(&hdr); e != nil {
+			return nil, e
+		}
+	}
+	if hdr.Network != DLT_DBUS {
+		return nil, ErrPcapFormat
+	}
+
+	msgs := make([]*Message, 0, 16)
+	for {
+		var rec pcapRecordHeader
+		e := binary.Read(r, order, &rec)
+		if e == os.EOF {
+			break
+		}
+		if e != nil {
+			return msgs, e
+		}
+
+		buff := make([]byte, rec.InclLen)
+		if _, e = io.ReadFull(r, buff); e != nil {
+			return msgs, e
+		}
+
+		msg, _, e := _Unmarshal(buff)
+		if e != nil {
+			return msgs, e
+		}
+
+		if len(msgs) == cap(msgs) {
+			tmp := make([]*Message, len(msgs), 2*cap(msgs))
+			copy(tmp, msgs)
+			msgs = tmp
+		}
+		msgs = msgs[0 : len(msgs)+1]
+		msgs[len(msgs)-1] = msg
+	}
+
+	return msgs, nil
+}
+
+// _SwapPcapHeader re-reads a header that was decoded with the wrong byte
+// order, failing if it is not a pcap header at all.
+func _SwapPcapHeader(hdr *pcapHeader) os.Error {
+	if hdr.Magic != 0xd4c3b2a1 {
+		return ErrPcapFormat
+	}
+	hdr.Magic = pcapMagic
+	hdr.VersionMajor = hdr.VersionMajor>>8 | hdr.VersionMajor<<8
+	hdr.VersionMinor = hdr.VersionMinor>>8 | hdr.VersionMinor<<8
+	hdr.SnapLen = _SwapUint32(hdr.SnapLen)
+	hdr.Network = _SwapUint32(hdr.Network)
+	return nil
+}
+
+func _SwapUint32(u uint32) uint32 {
+	return u>>24 | (u>>8)&0xff00 | (u<<8)&0xff0000 | u<<24
+}
@@ -0,0 +1,52 @@
+package dbus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPcapRoundTrip(t *testing.T) {
+	buff := bytes.NewBuffer([]byte{})
+	w, e := NewPcapWriter(buff)
+	if e != nil {
+		t.Error("#1 Failed")
+	}
+
+	msg := NewMessage()
+	msg.Type = METHOD_CALL
+	msg.Path = "/org/freedesktop/DBus"
+	msg.Dest = "org.freedesktop.DBus"
+	msg.Iface = "org.freedesktop.DBus"
+	msg.Member = "Hello"
+	w.WriteMessage(msg)
+
+	sig := NewMessage()
+	sig.Type = SIGNAL
+	sig.Sender = "org.freedesktop.DBus"
+	sig.Path = "/org/freedesktop/DBus"
+	sig.Iface = "org.freedesktop.DBus"
+	sig.Member = "NameAcquired"
+	sig.Sig = "s"
+	sig.Params.Push(":1.42")
+	w.WriteMessage(sig)
+
+	msgs, e := ReadPcap(buff)
+	if e != nil {
+		t.Error("#2 Failed:", e)
+	}
+	if len(msgs) != 2 {
+		t.Fatal("#3 Failed:", len(msgs))
+	}
+	if msgs[0].Type != METHOD_CALL || msgs[0].Member != "Hello" {
+		t.Error("#4 Failed")
+	}
+	if msgs[1].Sender != "org.freedesktop.DBus" || ":1.42" != msgs[1].Params.At(0).(string) {
+		t.Error("#5 Failed")
+	}
+}
+
+func TestReadPcapInvalid(t *testing.T) {
+	if _, e := ReadPcap(bytes.NewBufferString("not a pcap file at all")); e == nil {
+		t.Error("#1 Failed")
+	}
+}
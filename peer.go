@@ -0,0 +1,123 @@
+package dbus
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+const peerXMLIntro = `
+<node>
+  <interface name="org.freedesktop.DBus.Peer">
+    <method name="Ping"/>
+    <method name="GetMachineId">
+      <arg name="machine_uuid" type="s" direction="out"/>
+    </method>
+  </interface>
+</node>`
+
+const peerInterface = "org.freedesktop.DBus.Peer"
+
+var peerIntro, _ = NewIntrospect(peerXMLIntro)
+
+var machineIdFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// _MachineId returns the id of the local machine, as used by every D-Bus
+// implementation.
+func _MachineId() (string, os.Error) {
+	var err os.Error
+	for _, name := range machineIdFiles {
+		id, e := _ReadMachineId(name)
+		if e == nil {
+			return id, nil
+		}
+		err = e
+	}
+	return "", err
+}
+
+func _ReadMachineId(name string) (string, os.Error) {
+	f, e := os.Open(name, os.O_RDONLY, 0)
+	if e != nil {
+		return "", e
+	}
+	defer f.Close()
+
+	buff := make([]byte, 64)
+	n, e := f.Read(buff)
+	if e != nil {
+		return "", e
+	}
+	id := strings.TrimSpace(string(buff[0:n]))
+	if len(id) != 32 {
+		return "", os.NewError("Invalid machine id in " + name)
+	}
+	return id, nil
+}
+
+// _PeerReply answers the org.freedesktop.DBus.Peer methods, which every
+// connection implements on every path.
+func _PeerReply(call *Message) *Message {
+	switch call.Member {
+	case "Ping":
+		return NewMethodReturn(call, "")
+	case "GetMachineId":
+		id, e := _MachineId()
+		if e != nil {
+			return NewError(call, errorFailed, "s", e.String())
+		}
+		return NewMethodReturn(call, "s", id)
+	}
+	return NewError(call, errorUnknownMethod, "s",
+		"No such method '"+call.Member+"' in interface '"+peerInterface+"'")
+}
+
+func (p *Connection) _PeerInterface(dest string) *Interface {
+	obj := new(Object)
+	obj.conn = p
+	obj.dest = dest
+	obj.path = "/"
+	obj.intro = peerIntro
+
+	iface := new(Interface)
+	iface.obj = obj
+	iface.name = peerInterface
+	iface.intro = peerIntro.GetInterfaceData(peerInterface)
+	return iface
+}
+
+// Ping calls org.freedesktop.DBus.Peer.Ping on dest and returns the
+// round-trip time in nanoseconds.
+func (p *Connection) Ping(dest string) (int64, os.Error) {
+	return p.PingCancel(nil, 0, dest)
+}
+
+// PingCancel is like Ping, but bounded by cancel and timeout as with
+// CallMethodCancel.
+func (p *Connection) PingCancel(cancel <-chan bool, timeout int64, dest string) (int64, os.Error) {
+	start := time.Nanoseconds()
+	if _, e := p.CallMethodCancel(cancel, timeout, p._PeerInterface(dest), "Ping"); e != nil {
+		return 0, e
+	}
+	return time.Nanoseconds() - start, nil
+}
+
+// GetMachineId returns the machine id of the host dest runs on.
+func (p *Connection) GetMachineId(dest string) (string, os.Error) {
+	return p.GetMachineIdCancel(nil, 0, dest)
+}
+
+// GetMachineIdCancel is like GetMachineId, but bounded by cancel and
+// timeout as with CallMethodCancel.
+func (p *Connection) GetMachineIdCancel(cancel <-chan bool, timeout int64, dest string) (string, os.Error) {
+	ret, e := p.CallMethodCancel(cancel, timeout, p._PeerInterface(dest), "GetMachineId")
+	if e != nil {
+		return "", e
+	}
+	if 0 < len(ret) {
+		if id, ok := ret[0].(string); ok {
+			return id, nil
+		}
+	}
+	return "", os.NewError("Invalid GetMachineId reply")
+}
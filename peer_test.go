@@ -0,0 +1,51 @@
+package dbus
+
+import (
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	// Peer is answered on every path, exported or not
+	if rtt, e := client.Ping(""); e != nil || rtt < 0 {
+		t.Error("#1 Failed:", rtt, e)
+	}
+
+	reply := callPeer(client, "/no/such/object", peerInterface, "Ping", "")
+	if METHOD_RETURN != reply.Type {
+		t.Error("#2 Failed:", reply)
+	}
+}
+
+func TestGetMachineId(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	local, e := _MachineId()
+	id, re := client.GetMachineId("")
+	if e != nil {
+		// no machine id to answer with
+		if re == nil {
+			t.Error("#1 Failed")
+		}
+		return
+	}
+	if re != nil || local != id {
+		t.Error("#2 Failed:", id, re)
+	}
+}
+
+func TestPeerIntrospect(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	server.Export(new(testObject), "/org/test", "org.test")
+	if introspectPeer(t, client, "/org/test").GetInterfaceData(peerInterface) == nil {
+		t.Error("#1 Failed")
+	}
+}
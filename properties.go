@@ -0,0 +1,169 @@
+package dbus
+
+import (
+	"fmt"
+	"os"
+)
+
+const propertiesXMLIntro = `
+<node>
+  <interface name="org.freedesktop.DBus.Properties">
+    <method name="Get">
+      <arg name="interface_name" type="s" direction="in"/>
+      <arg name="property_name" type="s" direction="in"/>
+      <arg name="value" type="v" direction="out"/>
+    </method>
+    <method name="GetAll">
+      <arg name="interface_name" type="s" direction="in"/>
+      <arg name="props" type="a{sv}" direction="out"/>
+    </method>
+    <method name="Set">
+      <arg name="interface_name" type="s" direction="in"/>
+      <arg name="property_name" type="s" direction="in"/>
+      <arg name="value" type="v" direction="in"/>
+    </method>
+    <signal name="PropertiesChanged">
+      <arg name="interface_name" type="s"/>
+      <arg name="changed_properties" type="a{sv}"/>
+      <arg name="invalidated_properties" type="as"/>
+    </signal>
+  </interface>
+</node>`
+
+const propertiesInterface = "org.freedesktop.DBus.Properties"
+
+var propertiesIntro, _ = NewIntrospect(propertiesXMLIntro)
+
+// PropertiesChanged describes a PropertiesChanged signal: the new values
+// of Changed and the names of the Invalidated properties, whose values
+// have to be fetched again.
+type PropertiesChanged struct {
+	Interface   string
+	Changed     map[string]interface{}
+	Invalidated []string
+}
+
+func (p *Interface) _Properties() *Interface {
+	iface := new(Interface)
+	iface.obj = p.obj
+	iface.name = propertiesInterface
+	iface.intro = propertiesIntro.GetInterfaceData(propertiesInterface)
+	return iface
+}
+
+// _PropertyData returns the introspection data of a property, checking
+// that it can be accessed with mode ("read" or "write").
+func (p *Interface) _PropertyData(name string, mode string) (PropertyData, os.Error) {
+	prop := p.intro.GetPropertyData(name)
+	if prop == nil {
+		return nil, os.NewError(fmt.Sprintf("Invalid Property %s", name))
+	}
+	if access := prop.GetAccess(); access != mode && access != "readwrite" {
+		return nil, os.NewError(fmt.Sprintf("Property %s is not %sable", name, mode))
+	}
+	return prop, nil
+}
+
+// Get returns the value of the property name.
+func (p *Interface) Get(name string) (interface{}, os.Error) {
+	return p.GetCancel(nil, 0, name)
+}
+
+// GetCancel is like Get, but bounded by cancel and timeout as with
+// CallMethodCancel. SetCancel and GetAllCancel are the same for Set and
+// GetAll.
+func (p *Interface) GetCancel(cancel <-chan bool, timeout int64, name string) (interface{}, os.Error) {
+	if _, e := p._PropertyData(name, "read"); e != nil {
+		return nil, e
+	}
+
+	ret, e := p.obj.conn.CallMethodCancel(cancel, timeout, p._Properties(), "Get", p.name, name)
+	if e != nil {
+		return nil, e
+	}
+	if len(ret) == 0 {
+		return nil, os.NewError("Invalid Get reply")
+	}
+	return ret[0], nil
+}
+
+// Set changes the value of the property name. value is sent as a variant
+// of the property's introspected type.
+func (p *Interface) Set(name string, value interface{}) os.Error {
+	return p.SetCancel(nil, 0, name, value)
+}
+
+func (p *Interface) SetCancel(cancel <-chan bool, timeout int64, name string, value interface{}) os.Error {
+	prop, e := p._PropertyData(name, "write")
+	if e != nil {
+		return e
+	}
+
+	_, e = p.obj.conn.CallMethodCancel(cancel, timeout, p._Properties(), "Set", p.name, name, Variant{prop.GetSignature(), value})
+	return e
+}
+
+// GetAll returns the values of every readable property of the interface.
+func (p *Interface) GetAll() (map[string]interface{}, os.Error) {
+	return p.GetAllCancel(nil, 0)
+}
+
+func (p *Interface) GetAllCancel(cancel <-chan bool, timeout int64) (map[string]interface{}, os.Error) {
+	ret, e := p.obj.conn.CallMethodCancel(cancel, timeout, p._Properties(), "GetAll", p.name)
+	if e != nil {
+		return nil, e
+	}
+	if len(ret) == 0 {
+		return nil, os.NewError("Invalid GetAll reply")
+	}
+	return _PropertyMap(ret[0]), nil
+}
+
+// WatchProperties calls proc whenever the object emits PropertiesChanged
+// for this interface. proc runs on the run loop, as with AddSignalHandler.
+func (p *Interface) WatchProperties(proc func(*PropertiesChanged)) (*SignalHandle, os.Error) {
+	mr := &MatchRule{
+		Type:      "signal",
+		Sender:    p.obj.dest,
+		Path:      p.obj.path,
+		Interface: propertiesInterface,
+		Member:    "PropertiesChanged",
+		Args:      map[int]string{0: p.name},
+	}
+	return p.obj.conn.AddSignalHandler(mr, func(msg *Message) {
+		if changed := _ParsePropertiesChanged(msg); changed != nil {
+			proc(changed)
+		}
+	})
+}
+
+func _ParsePropertiesChanged(msg *Message) *PropertiesChanged {
+	if msg.Params.Len() < 3 {
+		return nil
+	}
+	name, ok := msg.Params.At(0).(string)
+	if !ok {
+		return nil
+	}
+
+	changed := &PropertiesChanged{Interface: name, Changed: _PropertyMap(msg.Params.At(1))}
+	invalidated := _ToSlice(msg.Params.At(2))
+	changed.Invalidated = make([]string, len(invalidated))
+	for i, v := range invalidated {
+		changed.Invalidated[i], _ = v.(string)
+	}
+	return changed
+}
+
+// _PropertyMap converts a parsed a{sv} to a map.
+func _PropertyMap(val interface{}) map[string]interface{} {
+	props := make(map[string]interface{})
+	for _, entry := range _ToSlice(val) {
+		if kv := _ToSlice(entry); len(kv) == 2 {
+			if name, ok := kv[0].(string); ok {
+				props[name] = kv[1]
+			}
+		}
+	}
+	return props
+}
@@ -0,0 +1,154 @@
+package dbus
+
+import (
+	"os"
+	"testing"
+)
+
+const testPropertiesXML = `
+<node>
+  <interface name="org.test">
+    <property name="Name" type="s" access="readwrite"/>
+    <property name="Count" type="u" access="read"/>
+    <property name="Secret" type="s" access="write"/>
+  </interface>
+</node>`
+
+// testProperties implements org.freedesktop.DBus.Properties by hand.
+type testProperties struct {
+	values map[string]interface{}
+}
+
+func (p *testProperties) Get(iface string, name string) (interface{}, os.Error) {
+	if v, ok := p.values[name]; ok {
+		return v, nil
+	}
+	return nil, &Error{errorInvalidArgs, []interface{}{"no such property"}}
+}
+
+func (p *testProperties) GetAll(iface string) map[string]interface{} { return p.values }
+
+func (p *testProperties) Set(iface string, name string, value interface{}) {
+	p.values[name] = value
+}
+
+// newPropertiesTest exports testProperties on a peer connection and
+// returns the client's view of it, or a nil Interface if introspection
+// failed.
+func newPropertiesTest() (*Connection, *Connection, *Interface, *testProperties) {
+	server, client := newPeerConnections()
+
+	props := &testProperties{map[string]interface{}{"Name": "test", "Count": uint32(3)}}
+	desc, _ := NewIntrospect(testPropertiesXML)
+	server.ExportWithIntrospection(new(testObject), "/org/test", desc.GetInterfaceData("org.test"))
+	server.Export(props, "/org/test", propertiesInterface)
+
+	iface := client.Interface(client.GetObject("", "/org/test"), "org.test")
+	return server, client, iface, props
+}
+
+func TestPropertiesGetSet(t *testing.T) {
+	server, client, iface, props := newPropertiesTest()
+	defer server.Close()
+	defer client.Close()
+
+	if iface == nil {
+		t.Fatal("#1 Failed")
+	}
+
+	if v, e := iface.Get("Name"); e != nil || "test" != v.(string) {
+		t.Error("#2 Failed:", v, e)
+	}
+	if v, e := iface.Get("Count"); e != nil || 3 != v.(uint32) {
+		t.Error("#3 Failed:", v, e)
+	}
+
+	if e := iface.Set("Name", "changed"); e != nil {
+		t.Error("#4 Failed:", e)
+	}
+	if v, _ := iface.Get("Name"); "changed" != v.(string) {
+		t.Error("#5 Failed:", props.values["Name"])
+	}
+
+	all, e := iface.GetAll()
+	if e != nil || 2 != len(all) || 3 != all["Count"].(uint32) {
+		t.Error("#6 Failed:", all, e)
+	}
+}
+
+func TestPropertiesAccess(t *testing.T) {
+	server, client, iface, _ := newPropertiesTest()
+	defer server.Close()
+	defer client.Close()
+
+	if iface == nil {
+		t.Fatal("#1 Failed")
+	}
+
+	// write-only, read-only and unknown properties
+	if _, e := iface.Get("Secret"); e == nil {
+		t.Error("#2 Failed")
+	}
+	if e := iface.Set("Count", uint32(1)); e == nil {
+		t.Error("#3 Failed")
+	}
+	if _, e := iface.Get("Missing"); e == nil {
+		t.Error("#4 Failed")
+	}
+}
+
+func TestWatchProperties(t *testing.T) {
+	server, client, iface, _ := newPropertiesTest()
+	defer server.Close()
+	defer client.Close()
+
+	if iface == nil {
+		t.Fatal("#1 Failed")
+	}
+
+	changes := make(chan *PropertiesChanged, 1)
+	if _, e := iface.WatchProperties(func(changed *PropertiesChanged) { changes <- changed }); e != nil {
+		t.Fatal("#2 Failed:", e)
+	}
+
+	msg := NewMessage()
+	msg.Type = SIGNAL
+	msg.Path = "/org/test"
+	msg.Iface = propertiesInterface
+	msg.Member = "PropertiesChanged"
+	msg.Sig = "sa{sv}as"
+	msg.Params.Push("org.test")
+	msg.Params.Push(map[string]interface{}{"Name": "new"})
+	msg.Params.Push([]string{"Count"})
+	server.Send(msg)
+
+	changed := <-changes
+	if "org.test" != changed.Interface || "new" != changed.Changed["Name"].(string) {
+		t.Error("#3 Failed:", changed)
+	}
+	if 1 != len(changed.Invalidated) || "Count" != changed.Invalidated[0] {
+		t.Error("#4 Failed:", changed.Invalidated)
+	}
+}
+
+func TestVariantMarshal(t *testing.T) {
+	msg := NewMessage()
+	msg.Sig = "vv"
+	msg.Params.Push(uint32(7))
+	msg.Params.Push(Variant{"as", []string{"a", "b"}})
+
+	buff, e := msg._Marshal()
+	if e != nil {
+		t.Fatal("#1 Failed:", e)
+	}
+	parsed, _, e := _Unmarshal(buff)
+	if e != nil {
+		t.Fatal("#2 Failed:", e)
+	}
+	if 7 != parsed.Params.At(0).(uint32) {
+		t.Error("#3 Failed:", parsed.Params.At(0))
+	}
+	if strs := _ToSlice(parsed.Params.At(1)); 2 != len(strs) || "b" != strs[1].(string) {
+		t.Error("#4 Failed:", parsed.Params.At(1))
+	}
+}
@@ -0,0 +1,311 @@
+package dbus
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	errorUnknownProperty  = "org.freedesktop.DBus.Error.UnknownProperty"
+	errorPropertyReadOnly = "org.freedesktop.DBus.Error.PropertyReadOnly"
+	errorAccessDenied     = "org.freedesktop.DBus.Error.AccessDenied"
+)
+
+const emitsChangedAnnotation = "org.freedesktop.DBus.Property.EmitsChangedSignal"
+
+// A PropertyStore holds the properties of one exported interface and
+// answers org.freedesktop.DBus.Properties calls for it. Changes, whether
+// made remotely or with Set, are announced with PropertiesChanged as the
+// EmitsChangedSignal annotation of each property, or else of the
+// interface, asks: "true" (the default) sends the new value,
+// "invalidates" only the name, and "const" and "false" nothing.
+type PropertyStore struct {
+	conn  *Connection
+	path  string
+	iface string
+	mutex sync.Mutex // guards props
+	props map[string]*storedProperty
+}
+
+type storedProperty struct {
+	data     propertyData
+	emits    string // EmitsChangedSignal of the interface
+	value    interface{}
+	validate func(interface{}) os.Error
+}
+
+func (p *storedProperty) _EmitsChanged() string {
+	return _AnnotationValue(p.data.Annotation, emitsChangedAnnotation, p.emits)
+}
+
+// _Check returns value as it is stored if it has the declared type of the
+// property. A Variant gives the signature of values it cannot be derived
+// from, such as parsed containers, and is unwrapped.
+func (p *storedProperty) _Check(value interface{}) (interface{}, os.Error) {
+	if value == nil {
+		return nil, &Error{errorInvalidArgs, []interface{}{fmt.Sprintf("No value for property '%s'", p.data.Name)}}
+	}
+	sig, inner, e := _VariantOf(value)
+	if e != nil {
+		return nil, &Error{errorInvalidArgs, []interface{}{
+			fmt.Sprintf("Property '%s' has type '%s': %s", p.data.Name, p.data.Type, e.String())}}
+	}
+	if p.data.Type == "v" {
+		return value, nil
+	}
+	if sig != p.data.Type {
+		return nil, &Error{errorInvalidArgs, []interface{}{
+			fmt.Sprintf("Property '%s' has type '%s', not '%s'", p.data.Name, p.data.Type, sig)}}
+	}
+	return inner, nil
+}
+
+func (p *storedProperty) _Readable() bool {
+	return p.data.Access == "read" || p.data.Access == "readwrite"
+}
+
+func (p *storedProperty) _Writable() bool {
+	return p.data.Access == "write" || p.data.Access == "readwrite"
+}
+
+// ExportProperties serves the properties intro declares on the object at
+// path, starting with the given values, which must have the declared
+// types as with Set. Methods of the interface can be exported separately
+// with Export or ExportWithIntrospection. Once the interface is unexported
+// or its properties exported again, the store no longer announces changes.
+func (p *Connection) ExportProperties(path string, intro InterfaceData, values map[string]interface{}) (*PropertyStore, os.Error) {
+	data, ok := intro.(interfaceData)
+	if !ok {
+		return nil, os.NewError("Unsupported InterfaceData")
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		return nil, os.NewError("Invalid object path")
+	}
+
+	store := &PropertyStore{conn: p, path: path, iface: data.Name}
+	store.props = make(map[string]*storedProperty)
+	emits := _AnnotationValue(data.Annotation, emitsChangedAnnotation, "true")
+	for _, prop := range data.Property {
+		stored := &storedProperty{data: prop, emits: emits}
+		if value, ok := values[prop.Name]; ok && value != nil {
+			checked, e := stored._Check(value)
+			if e != nil {
+				return nil, e
+			}
+			stored.value = checked
+		}
+		store.props[prop.Name] = stored
+	}
+
+	p.exportMutex.Lock()
+	ifaces, ok := p.exports[path]
+	if !ok {
+		ifaces = make(map[string]*exportedInterface)
+		p.exports[path] = ifaces
+	}
+	exported, found := ifaces[data.Name]
+	if !found {
+		exported = &exportedInterface{intro: data, methods: _MethodsOf(nil, data)}
+		ifaces[data.Name] = exported
+	}
+	exported.intro.Property = data.Property
+	exported.props = store
+	p.exportMutex.Unlock()
+
+	if !found {
+		p._EmitInterfacesAdded(path, data.Name)
+	}
+	return store, nil
+}
+
+// OnSet makes Set, local or remote, call validate with the new value of
+// the property name first. A non-nil error rejects the change and is
+// returned to the caller as with exported methods.
+func (p *PropertyStore) OnSet(name string, validate func(value interface{}) os.Error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if prop, ok := p.props[name]; ok {
+		prop.validate = validate
+	}
+}
+
+// Get returns the current value of the property name.
+func (p *PropertyStore) Get(name string) (interface{}, os.Error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	prop, ok := p.props[name]
+	if !ok {
+		return nil, &Error{errorUnknownProperty, []interface{}{fmt.Sprintf("No such property '%s'", name)}}
+	}
+	return prop.value, nil
+}
+
+// Set changes the property name regardless of its access and announces
+// the change. Properties annotated as const cannot be changed. value must
+// have the declared type; values whose signature cannot be derived from
+// their Go type, such as parsed containers, can be given as a Variant.
+func (p *PropertyStore) Set(name string, value interface{}) os.Error {
+	p.mutex.Lock()
+	prop, ok := p.props[name]
+	p.mutex.Unlock()
+
+	if !ok {
+		return &Error{errorUnknownProperty, []interface{}{fmt.Sprintf("No such property '%s'", name)}}
+	}
+	return p._Set(prop, value)
+}
+
+func (p *PropertyStore) _Set(prop *storedProperty, value interface{}) os.Error {
+	if prop._EmitsChanged() == "const" {
+		return &Error{errorPropertyReadOnly, []interface{}{fmt.Sprintf("Property '%s' is constant", prop.data.Name)}}
+	}
+	value, e := prop._Check(value)
+	if e != nil {
+		return e
+	}
+
+	p.mutex.Lock()
+	validate := prop.validate
+	p.mutex.Unlock()
+
+	if validate != nil {
+		if e := validate(value); e != nil {
+			return e
+		}
+	}
+
+	p.mutex.Lock()
+	prop.value = value
+	p.mutex.Unlock()
+
+	return p._EmitChanged(prop, value)
+}
+
+func (p *PropertyStore) _EmitChanged(prop *storedProperty, value interface{}) os.Error {
+	// nobody can ask an unexported or replaced store for its values
+	if p != p.conn._PropertyStore(p.path, p.iface) {
+		return nil
+	}
+
+	changed := make(map[string]interface{})
+	invalidated := []string{}
+
+	switch prop._EmitsChanged() {
+	case "true":
+		changed[prop.data.Name] = Variant{prop.data.Type, value}
+	case "invalidates":
+		invalidated = []string{prop.data.Name}
+	default:
+		return nil
+	}
+
+	msg := NewMessage()
+	msg.Type = SIGNAL
+	msg.Path = p.path
+	msg.Iface = propertiesInterface
+	msg.Member = "PropertiesChanged"
+	msg.Sig = "sa{sv}as"
+	msg.Params.Push(p.iface)
+	msg.Params.Push(changed)
+	msg.Params.Push(invalidated)
+
+	return p.conn.Send(msg)
+}
+
+// _Reply answers a Properties call for this interface.
+func (p *PropertyStore) _Reply(call *Message) *Message {
+	args := call.Params.Data()
+	name := ""
+	if 1 < len(args) {
+		name, _ = args[1].(string)
+	}
+
+	switch call.Member {
+	case "Get":
+		if len(args) != 2 {
+			break
+		}
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+
+		prop, ok := p.props[name]
+		if !ok {
+			return NewError(call, errorUnknownProperty, "s", fmt.Sprintf("No such property '%s'", name))
+		}
+		if !prop._Readable() {
+			return NewError(call, errorAccessDenied, "s", fmt.Sprintf("Property '%s' is not readable", name))
+		}
+		if prop.value == nil {
+			return NewError(call, errorFailed, "s", fmt.Sprintf("Property '%s' has no value", name))
+		}
+		return NewMethodReturn(call, "v", Variant{prop.data.Type, prop.value})
+
+	case "Set":
+		if len(args) != 3 {
+			break
+		}
+		p.mutex.Lock()
+		prop, ok := p.props[name]
+		p.mutex.Unlock()
+
+		if !ok {
+			return NewError(call, errorUnknownProperty, "s", fmt.Sprintf("No such property '%s'", name))
+		}
+		if !prop._Writable() {
+			return NewError(call, errorPropertyReadOnly, "s", fmt.Sprintf("Property '%s' is not writable", name))
+		}
+		// the value with the signature it was sent with
+		value := args[2]
+		if typed := call._TypedParams(); len(typed) == 3 {
+			value = typed[2]
+		}
+		if e := p._Set(prop, value); e != nil {
+			return _ErrorToMessage(call, e)
+		}
+		return NewMethodReturn(call, "")
+
+	case "GetAll":
+		if len(args) != 1 {
+			break
+		}
+		return NewMethodReturn(call, "a{sv}", p._Values())
+
+	default:
+		return NewError(call, errorUnknownMethod, "s",
+			fmt.Sprintf("No such method '%s' in interface '%s'", call.Member, propertiesInterface))
+	}
+
+	return NewError(call, errorInvalidArgs, "s", fmt.Sprintf("Invalid arguments to '%s'", call.Member))
+}
+
+// _Values returns the readable properties as variants, as GetAll does.
+func (p *PropertyStore) _Values() map[string]interface{} {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	values := make(map[string]interface{})
+	for name, prop := range p.props {
+		if prop._Readable() && prop.value != nil {
+			values[name] = Variant{prop.data.Type, prop.value}
+		}
+	}
+	return values
+}
+
+// _PropertyStore returns the store serving iface at path, or nil.
+func (p *Connection) _PropertyStore(path string, iface string) *PropertyStore {
+	p.exportMutex.RLock()
+	defer p.exportMutex.RUnlock()
+
+	if ifaces, ok := p.exports[path]; ok {
+		if exported, ok := ifaces[iface]; ok {
+			return exported.props
+		}
+	}
+	return nil
+}
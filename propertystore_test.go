@@ -0,0 +1,235 @@
+package dbus
+
+import (
+	"os"
+	"testing"
+)
+
+const testStoreXML = `
+<node>
+  <interface name="org.test.Store">
+    <property name="Name" type="s" access="readwrite"/>
+    <property name="Level" type="i" access="readwrite">
+      <annotation name="org.freedesktop.DBus.Property.EmitsChangedSignal" value="invalidates"/>
+    </property>
+    <property name="Serial" type="s" access="read">
+      <annotation name="org.freedesktop.DBus.Property.EmitsChangedSignal" value="const"/>
+    </property>
+    <property name="Quiet" type="u" access="readwrite">
+      <annotation name="org.freedesktop.DBus.Property.EmitsChangedSignal" value="false"/>
+    </property>
+  </interface>
+</node>`
+
+// newStoreTest exports a PropertyStore for testStoreXML on a peer
+// connection and returns the client's view of it. The store is nil if
+// the export failed and the Interface nil if introspection did.
+func newStoreTest() (*Connection, *Connection, *PropertyStore, *Interface) {
+	server, client := newPeerConnections()
+
+	desc, _ := NewIntrospect(testStoreXML)
+	store, _ := server.ExportProperties("/org/test", desc.GetInterfaceData("org.test.Store"),
+		map[string]interface{}{"Name": "a", "Level": int32(1), "Serial": "123", "Quiet": uint32(0)})
+
+	iface := client.Interface(client.GetObject("", "/org/test"), "org.test.Store")
+	return server, client, store, iface
+}
+
+func TestPropertyStore(t *testing.T) {
+	server, client, store, iface := newStoreTest()
+	defer server.Close()
+	defer client.Close()
+
+	if store == nil || iface == nil {
+		t.Fatal("#1 Failed")
+	}
+
+	if v, e := iface.Get("Serial"); e != nil || "123" != v.(string) {
+		t.Error("#2 Failed:", v, e)
+	}
+
+	if e := iface.Set("Name", "b"); e != nil {
+		t.Error("#3 Failed:", e)
+	}
+	if v, _ := store.Get("Name"); "b" != v.(string) {
+		t.Error("#4 Failed:", v)
+	}
+
+	all, e := iface.GetAll()
+	if e != nil || 4 != len(all) || 1 != all["Level"].(int32) {
+		t.Error("#5 Failed:", all, e)
+	}
+
+	// a value of the wrong type, a const and an unknown property
+	if e := iface.Set("Level", "wrong type"); e == nil {
+		t.Error("#6 Failed")
+	}
+	if e := store.Set("Serial", "456"); e == nil {
+		t.Error("#7 Failed")
+	}
+	if e := store.Set("Missing", "x"); e == nil {
+		t.Error("#8 Failed")
+	}
+}
+
+func TestPropertyStoreValidation(t *testing.T) {
+	server, client, store, iface := newStoreTest()
+	defer server.Close()
+	defer client.Close()
+
+	if store == nil || iface == nil {
+		t.Fatal("#1 Failed")
+	}
+
+	store.OnSet("Level", func(value interface{}) os.Error {
+		if value.(int32) < 0 {
+			return &Error{"org.test.Error.Range", []interface{}{"negative level"}}
+		}
+		return nil
+	})
+
+	e := iface.Set("Level", int32(-1))
+	if err, ok := e.(*Error); !ok || "org.test.Error.Range" != err.Name {
+		t.Error("#2 Failed:", e)
+	}
+	if v, _ := store.Get("Level"); 1 != v.(int32) {
+		t.Error("#3 Failed:", v)
+	}
+	if e := iface.Set("Level", int32(5)); e != nil {
+		t.Error("#4 Failed:", e)
+	}
+
+	// values of the wrong type, or of no D-Bus type, are rejected
+	if e := store.Set("Name", []int32{1}); e == nil {
+		t.Error("#5 Failed")
+	}
+	if e := store.Set("Name", make(chan int)); e == nil {
+		t.Error("#6 Failed")
+	}
+	if e := store.Set("Name", Variant{"as", []string{"a"}}); e == nil {
+		t.Error("#7 Failed")
+	}
+	if e := store.Set("Name", Variant{"s", "d"}); e != nil {
+		t.Error("#8 Failed:", e)
+	}
+	if v, _ := store.Get("Name"); "d" != v {
+		t.Error("#9 Failed:", v)
+	}
+}
+
+func TestPropertyStoreChanged(t *testing.T) {
+	server, client, store, iface := newStoreTest()
+	defer server.Close()
+	defer client.Close()
+
+	if store == nil || iface == nil {
+		t.Fatal("#1 Failed")
+	}
+
+	changes := make(chan *PropertiesChanged, 10)
+	if _, e := iface.WatchProperties(func(changed *PropertiesChanged) { changes <- changed }); e != nil {
+		t.Fatal("#2 Failed:", e)
+	}
+
+	store.Set("Quiet", uint32(1)) // no signal
+	store.Set("Name", "c")
+	store.Set("Level", int32(2))
+
+	changed := <-changes
+	if 1 != len(changed.Changed) || "c" != changed.Changed["Name"].(string) || 0 != len(changed.Invalidated) {
+		t.Error("#3 Failed:", changed)
+	}
+	changed = <-changes
+	if 0 != len(changed.Changed) || 1 != len(changed.Invalidated) || "Level" != changed.Invalidated[0] {
+		t.Error("#4 Failed:", changed)
+	}
+}
+
+func TestPropertyStoreUnexported(t *testing.T) {
+	server, client, store, iface := newStoreTest()
+	defer server.Close()
+	defer client.Close()
+
+	if store == nil || iface == nil {
+		t.Fatal("#1 Failed")
+	}
+
+	changes := make(chan *PropertiesChanged, 10)
+	if _, e := iface.WatchProperties(func(changed *PropertiesChanged) { changes <- changed }); e != nil {
+		t.Fatal("#2 Failed:", e)
+	}
+
+	server.Unexport("/org/test", "org.test.Store")
+	store.Set("Name", "unexported")
+
+	desc, _ := NewIntrospect(testStoreXML)
+	replaced, e := server.ExportProperties("/org/test", desc.GetInterfaceData("org.test.Store"), nil)
+	if e != nil {
+		t.Fatal("#3 Failed:", e)
+	}
+	store.Set("Name", "replaced")
+	replaced.Set("Name", "current")
+
+	changed := <-changes
+	if name, _ := changed.Changed["Name"].(string); "current" != name {
+		t.Error("#4 Failed:", changed)
+	}
+}
+
+func TestPropertyStoreInitialValues(t *testing.T) {
+	con, _ := newTestConnection()
+	defer con.Close()
+
+	desc, _ := NewIntrospect(testStoreXML)
+	intro := desc.GetInterfaceData("org.test.Store")
+
+	if _, e := con.ExportProperties("/org/test", intro, map[string]interface{}{"Level": "wrong type"}); e == nil {
+		t.Error("#1 Failed")
+	}
+	if nil != con._PropertyStore("/org/test", "org.test.Store") {
+		t.Error("#2 Failed")
+	}
+
+	store, e := con.ExportProperties("/org/test", intro,
+		map[string]interface{}{"Name": Variant{"s", "a"}, "Level": int32(1)})
+	if e != nil {
+		t.Fatal("#3 Failed:", e)
+	}
+	if v, _ := store.Get("Name"); "a" != v.(string) {
+		t.Error("#4 Failed:", v)
+	}
+}
+
+func TestPropertyStoreIntrospect(t *testing.T) {
+	server, client, store, _ := newStoreTest()
+	defer server.Close()
+	defer client.Close()
+
+	if store == nil {
+		t.Fatal("#1 Failed")
+	}
+
+	intro := introspectPeer(t, client, "/org/test")
+	if intro.GetInterfaceData(propertiesInterface) == nil {
+		t.Error("#2 Failed")
+	}
+	iface := intro.GetInterfaceData("org.test.Store")
+	if iface == nil || iface.GetPropertyData("Level") == nil {
+		t.Error("#3 Failed")
+	}
+}
+
+func TestPropertyStoreInterfaceAnnotation(t *testing.T) {
+	con, _ := newTestConnection()
+	defer con.Close()
+
+	desc, _ := NewIntrospect(annotatedStr)
+	store, e := con.ExportProperties("/org/test", desc.GetInterfaceData("org.test.Annotated"), nil)
+	if e != nil {
+		t.Fatal("#1 Failed:", e)
+	}
+	// the interface annotation applies to properties without their own
+	if emits := store.props["Count"]._EmitsChanged(); "invalidates" != emits {
+		t.Error("#2 Failed:", emits)
+	}
+}
@@ -0,0 +1,220 @@
+package dbus
+
+import (
+	"bytes"
+	"container/vector"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultReconnectDelay    = 1e8  // 100ms
+	defaultReconnectMaxDelay = 30e9 // 30s
+)
+
+// A ReconnectPolicy makes a Connection re-dial the bus when it hangs up
+// instead of shutting down. Delays are in nanoseconds and double after
+// every failed attempt.
+type ReconnectPolicy struct {
+	InitialDelay int64 // defaults to 100ms
+	MaxDelay     int64 // defaults to 30s
+	MaxAttempts  int   // 0 retries forever
+
+	// OnReconnect, if set, is called with the new unique name once the
+	// connection has been re-established and its match rules and names
+	// restored. err is nil if everything was restored and a
+	// *ReregisterError otherwise.
+	OnReconnect func(uniqName string, err os.Error)
+}
+
+// A ReregisterError lists the match rules and names that could not be
+// restored after a reconnect, with the reason for each. Their handlers
+// and requests are kept and retried on the next reconnect.
+type ReregisterError struct {
+	Rules map[string]os.Error
+	Names map[string]os.Error
+}
+
+func (p *ReregisterError) String() string {
+	failed := new(vector.StringVector)
+	for rule, e := range p.Rules {
+		failed.Push(fmt.Sprintf("match rule %q: %s", rule, e))
+	}
+	for name, e := range p.Names {
+		failed.Push(fmt.Sprintf("name %s: %s", name, e))
+	}
+	sort.SortStrings(failed.Data())
+	return "Reconnect: not restored: " + strings.Join(failed.Data(), "; ")
+}
+
+// SetReconnectPolicy enables automatic reconnection. It may be called at
+// any time and applies from the next time the connection breaks; a nil
+// policy disables reconnection.
+func (p *Connection) SetReconnectPolicy(policy *ReconnectPolicy) {
+	p.replyMutex.Lock()
+	p.reconnect = policy
+	p.replyMutex.Unlock()
+}
+
+func (p *Connection) _ReconnectPolicy() *ReconnectPolicy {
+	p.replyMutex.Lock()
+	defer p.replyMutex.Unlock()
+	return p.reconnect
+}
+
+// _Reconnect is called by the receiver after the connection broke. It
+// fails the calls that can no longer be answered, re-dials with backoff
+// and reports whether a new connection is in place.
+func (p *Connection) _Reconnect() bool {
+	policy := p._ReconnectPolicy()
+	if policy == nil || p.dial == nil || p._IsClosed() {
+		return false
+	}
+
+	p._FailPending()
+
+	delay := policy.InitialDelay
+	if delay <= 0 {
+		delay = defaultReconnectDelay
+	}
+	maxDelay := policy.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultReconnectMaxDelay
+	}
+
+	for attempt := 1; policy.MaxAttempts == 0 || attempt <= policy.MaxAttempts; attempt++ {
+		if !p._SleepUnlessClosed(delay) {
+			return false
+		}
+
+		conn, buffer, name, e := p._Redial()
+		if e == nil {
+			p.buffer = buffer
+			p._SetConn(conn)
+			p._SetUniqName(name)
+			if p._IsClosed() {
+				conn.Close()
+				return false
+			}
+			go p._Reregister(name, policy)
+			return true
+		}
+
+		delay *= 2
+		if maxDelay < delay {
+			delay = maxDelay
+		}
+	}
+	return false
+}
+
+func (p *Connection) _SleepUnlessClosed(delay int64) bool {
+	timer := make(chan bool, 1)
+	go func() {
+		time.Sleep(delay)
+		timer <- true
+	}()
+
+	select {
+	case <-timer:
+		return true
+	case <-p.closed:
+	}
+	return false
+}
+
+// _Redial dials and authenticates a new connection and sends Hello on it
+// before anyone else can write to it, as the bus requires.
+func (p *Connection) _Redial() (conn net.Conn, buffer *bytes.Buffer, name string, e os.Error) {
+	if conn, e = p.dial(); e != nil {
+		return
+	}
+	if e = p._Auth(conn); e != nil {
+		conn.Close()
+		return
+	}
+
+	buffer = bytes.NewBuffer([]byte{})
+	if name, e = _HelloSync(conn, buffer); e != nil {
+		conn.Close()
+	}
+	return
+}
+
+// _HelloSync sends Hello on conn and reads until its reply arrives.
+// Anything received after the reply is left in buffer.
+func _HelloSync(conn net.Conn, buffer *bytes.Buffer) (string, os.Error) {
+	hello := NewMessage()
+	hello.Type = METHOD_CALL
+	hello.Path = "/org/freedesktop/DBus"
+	hello.Dest = "org.freedesktop.DBus"
+	hello.Iface = "org.freedesktop.DBus"
+	hello.Member = "Hello"
+
+	buff, _ := hello._Marshal()
+	if _, e := conn.Write(buff); e != nil {
+		return "", e
+	}
+
+	b := make([]byte, 4096)
+	for {
+		msg, n, e := _Unmarshal(buffer.Bytes())
+		if e != nil {
+			rn, re := conn.Read(b)
+			if re != nil {
+				return "", re
+			}
+			buffer.Write(b[0:rn])
+			continue
+		}
+		buffer.Read(make([]byte, n))
+
+		if msg.replySerial != uint32(hello.serial) {
+			continue
+		}
+		if msg.Type == ERROR {
+			return "", &Error{msg.ErrorName, msg.Params.Data()}
+		}
+		if 0 < msg.Params.Len() {
+			if name, ok := msg.Params.At(0).(string); ok {
+				return name, nil
+			}
+		}
+		return "", os.NewError("Invalid Hello reply")
+	}
+	panic("unreachable")
+}
+
+// _Reregister restores the bus-side state of the connection after a
+// reconnect: every match rule is added again and every name requested
+// again, then policy is told about the new name and what failed.
+func (p *Connection) _Reregister(name string, policy *ReconnectPolicy) {
+	failed := &ReregisterError{make(map[string]os.Error), make(map[string]os.Error)}
+	for _, rule := range p._MatchRules() {
+		if _, e := p.CallMethod(p.proxy, "AddMatch", rule); e != nil {
+			failed.Rules[rule] = e
+		}
+	}
+	for busName, flags := range p._RequestedNames() {
+		reply, e := p.RequestName(busName, flags)
+		if e == nil && REQUEST_NAME_REPLY_EXISTS == reply {
+			e = os.NewError("owned by another connection")
+		}
+		if e != nil {
+			failed.Names[busName] = e
+		}
+	}
+
+	if policy.OnReconnect == nil {
+		return
+	}
+	if 0 < len(failed.Rules)+len(failed.Names) {
+		policy.OnReconnect(name, failed)
+	} else {
+		policy.OnReconnect(name, nil)
+	}
+}
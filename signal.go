@@ -0,0 +1,296 @@
+package dbus
+
+import (
+	"container/vector"
+	"os"
+)
+
+// SignalBufferSize is the capacity of the channels returned by Subscribe.
+const SignalBufferSize = 64
+
+// Signal delivers every signal the connection receives to ch. Sends never
+// block: when ch is full the signal is dropped and counted by
+// DroppedSignals. ch is closed when the connection shuts down.
+func (p *Connection) Signal(ch chan<- *Message) {
+	p.handlerMutex.Lock()
+	p.signalMatchRules.Push(&signalHandler{ch: ch})
+	p.handlerMutex.Unlock()
+}
+
+// RemoveSignal stops delivery to a channel registered with Signal.
+func (p *Connection) RemoveSignal(ch chan<- *Message) {
+	p.handlerMutex.Lock()
+	defer p.handlerMutex.Unlock()
+
+	for i := 0; i < p.signalMatchRules.Len(); i++ {
+		if p.signalMatchRules.At(i).(*signalHandler).ch == ch {
+			p.signalMatchRules.Delete(i)
+			return
+		}
+	}
+}
+
+// Subscribe adds mr to the bus and returns a buffered channel receiving
+// the signals matching it, with the same non-blocking semantics as Signal.
+// Calling cancel removes the subscription and closes the channel. If the
+// bus rejects the rule nothing is subscribed and the error is returned.
+func (p *Connection) Subscribe(mr *MatchRule) (<-chan *Message, func(), os.Error) {
+	return p._Subscribe(mr, false)
+}
+
+// SubscribeMessages is like Subscribe, but delivers messages of every
+// type matching mr: method calls, returns and errors as well as signals.
+// Together with an eavesdrop='true' rule this lets a monitor see traffic
+// between other connections; replies to our own calls are still routed
+// to their callers as usual.
+func (p *Connection) SubscribeMessages(mr *MatchRule) (<-chan *Message, func(), os.Error) {
+	return p._Subscribe(mr, true)
+}
+
+func (p *Connection) _Subscribe(mr *MatchRule, anyType bool) (<-chan *Message, func(), os.Error) {
+	ch := make(chan *Message, SignalBufferSize)
+	handler := &signalHandler{mr: *mr, ch: ch, anyType: anyType}
+	if e := p._RegisterHandler(handler); e != nil {
+		return nil, nil, e
+	}
+
+	cancel := func() {
+		if p._RemoveHandler(handler) {
+			close(ch)
+			p._RemoveMatch(&handler.mr)
+		}
+	}
+	return ch, cancel, nil
+}
+
+// AddMessageHandler is like AddSignalHandler, but proc receives messages
+// of every type matching mr, as with SubscribeMessages.
+func (p *Connection) AddMessageHandler(mr *MatchRule, proc func(*Message)) (*SignalHandle, os.Error) {
+	return p._AddHandler(&signalHandler{mr: *mr, proc: proc, anyType: true})
+}
+
+func (p *Connection) _AddHandler(handler *signalHandler) (*SignalHandle, os.Error) {
+	if e := p._RegisterHandler(handler); e != nil {
+		return nil, e
+	}
+	return &SignalHandle{p, handler}, nil
+}
+
+// _RegisterHandler installs handler and adds its rule to the bus. The
+// handler is installed first so that nothing the bus sends once it has
+// accepted the rule is missed, and removed again if the bus rejects it.
+func (p *Connection) _RegisterHandler(handler *signalHandler) os.Error {
+	if e := handler.mr._Check(); e != nil {
+		return e
+	}
+	if e := p._WatchSender(handler); e != nil {
+		return e
+	}
+
+	p.handlerMutex.Lock()
+	p.signalMatchRules.Push(handler)
+	p.handlerMutex.Unlock()
+
+	if e := p._AddMatch(&handler.mr); e != nil {
+		p._RemoveHandler(handler)
+		return e
+	}
+	return nil
+}
+
+// _WatchSender makes handler follow the owner of a well-known sender, so
+// that, as on the bus, only messages from the current owner match.
+func (p *Connection) _WatchSender(handler *signalHandler) os.Error {
+	if !_IsWellKnownName(handler.mr.Sender) {
+		return nil
+	}
+	watch, e := p.WatchName(handler.mr.Sender, nil, nil)
+	if e != nil {
+		return e
+	}
+	<-watch.ready
+	handler.owner = watch
+	return nil
+}
+
+// A SignalHandle identifies a handler registered with AddSignalHandler
+// or AddMessageHandler.
+type SignalHandle struct {
+	conn    *Connection
+	handler *signalHandler
+}
+
+// Remove unregisters the handler. The match rule is removed from the bus
+// once no other handler or subscription uses it.
+func (p *SignalHandle) Remove() os.Error {
+	if !p.conn._RemoveHandler(p.handler) {
+		return os.NewError("Handler not registered")
+	}
+	return p.conn._RemoveMatch(&p.handler.mr)
+}
+
+// A pendingMatch is an AddMatch in flight. done is closed once the bus
+// has answered, with err set if it rejected the rule.
+type pendingMatch struct {
+	done chan bool
+	err  os.Error
+}
+
+// _AddMatch adds mr to the bus unless an identical rule is already in
+// use. The call is sent under the lock, so that AddMatch and RemoveMatch
+// for a rule reach the bus in order, but its reply is awaited outside it.
+// Callers adding a rule that is still in flight wait for its answer, and
+// a reference is only counted once the bus has accepted the rule.
+func (p *Connection) _AddMatch(mr *MatchRule) os.Error {
+	rule := mr._ToString()
+
+	p.matchMutex.Lock()
+	for {
+		if 0 < p.matchRefs[rule] {
+			p.matchRefs[rule]++
+			p.matchMutex.Unlock()
+			return nil
+		}
+		pending, ok := p.matchPending[rule]
+		if !ok {
+			break
+		}
+		p.matchMutex.Unlock()
+		<-pending.done
+		if pending.err != nil {
+			return pending.err
+		}
+		// the rule may have been removed again before we got the lock
+		p.matchMutex.Lock()
+	}
+
+	pending := &pendingMatch{done: make(chan bool)}
+	p.matchPending[rule] = pending
+	call := p.Go(p.proxy, "AddMatch", nil, rule)
+	p.matchMutex.Unlock()
+
+	call = <-call.Done
+
+	p.matchMutex.Lock()
+	p.matchPending[rule] = nil, false
+	if call.Err == nil {
+		p.matchRefs[rule]++
+	}
+	pending.err = call.Err
+	p.matchMutex.Unlock()
+
+	close(pending.done)
+	return call.Err
+}
+
+// _RemoveMatch drops one reference to mr and removes it from the bus when
+// the last one goes away.
+func (p *Connection) _RemoveMatch(mr *MatchRule) os.Error {
+	rule := mr._ToString()
+
+	p.matchMutex.Lock()
+	switch p.matchRefs[rule] {
+	case 0:
+		p.matchMutex.Unlock()
+		return nil
+	case 1:
+		p.matchRefs[rule] = 0, false
+		call := p.Go(p.proxy, "RemoveMatch", nil, rule)
+		p.matchMutex.Unlock()
+		return (<-call.Done).Err
+	}
+	p.matchRefs[rule]--
+	p.matchMutex.Unlock()
+	return nil
+}
+
+// _MatchRules returns every rule currently added to the bus.
+func (p *Connection) _MatchRules() []string {
+	p.matchMutex.Lock()
+	defer p.matchMutex.Unlock()
+
+	rules := make([]string, len(p.matchRefs))
+	i := 0
+	for rule := range p.matchRefs {
+		rules[i] = rule
+		i++
+	}
+	return rules
+}
+
+// DroppedSignals returns how many signals were discarded because a
+// subscriber's channel was full.
+func (p *Connection) DroppedSignals() int64 {
+	p.handlerMutex.RLock()
+	defer p.handlerMutex.RUnlock()
+	return p.dropped
+}
+
+func (p *Connection) _RemoveHandler(handler *signalHandler) bool {
+	removed := false
+	p.handlerMutex.Lock()
+	for i := 0; i < p.signalMatchRules.Len(); i++ {
+		if p.signalMatchRules.At(i).(*signalHandler) == handler {
+			p.signalMatchRules.Delete(i)
+			removed = true
+			break
+		}
+	}
+	p.handlerMutex.Unlock()
+
+	if removed && handler.owner != nil {
+		handler.owner.Stop()
+	}
+	return removed
+}
+
+// _DeliverMessage sends msg to every matching channel and returns the
+// matching callbacks. Channels are written under the lock so that they
+// cannot be closed concurrently.
+func (p *Connection) _DeliverMessage(msg *Message) []func(*Message) {
+	forUs := p._IsForUs(msg)
+
+	p.handlerMutex.Lock()
+	defer p.handlerMutex.Unlock()
+
+	procs := make([]func(*Message), 0, p.signalMatchRules.Len())
+	for _, v := range p.signalMatchRules.Data() {
+		handler := v.(*signalHandler)
+		if msg.Type != SIGNAL && !handler.anyType {
+			continue
+		}
+		// without eavesdrop the bus only routes messages meant for us
+		if !forUs && !handler.mr.Eavesdrop {
+			continue
+		}
+		if !handler.mr._Match(msg, handler._Owner()) {
+			continue
+		}
+		if handler.ch == nil {
+			procs = procs[0 : len(procs)+1]
+			procs[len(procs)-1] = handler.proc
+			continue
+		}
+		select {
+		case handler.ch <- msg:
+		default:
+			p.dropped++
+		}
+	}
+	return procs
+}
+
+func (p *Connection) _CloseSignalChannels() {
+	p.handlerMutex.Lock()
+	defer p.handlerMutex.Unlock()
+
+	handlers := p.signalMatchRules.Data()
+	p.signalMatchRules = new(vector.Vector)
+	for _, v := range handlers {
+		if handler := v.(*signalHandler); handler.ch != nil {
+			close(handler.ch)
+		} else {
+			p.signalMatchRules.Push(handler)
+		}
+	}
+}
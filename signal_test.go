@@ -0,0 +1,274 @@
+package dbus
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	con, bus := newTestConnection()
+
+	ch, cancel, _ := con.Subscribe(&MatchRule{Type: "signal", Member: "Tick"})
+	bus._EmitSignal("Tock")
+	bus._EmitSignal("Tick")
+
+	if msg := <-ch; "Tick" != msg.Member {
+		t.Error("#1 Failed:", msg.Member)
+	}
+
+	cancel()
+	if msg := <-ch; msg != nil || !closed(ch) {
+		t.Error("#2 Failed")
+	}
+	bus.mutex.Lock()
+	if 1 != bus.matches.Len() || 1 != bus.removed.Len() {
+		t.Error("#3 Failed")
+	}
+	bus.mutex.Unlock()
+}
+
+func TestSubscribeRejected(t *testing.T) {
+	con, bus := newTestConnection()
+	bus.refused = "AddMatch"
+
+	if _, _, e := con.Subscribe(&MatchRule{Type: "signal", Member: "Tick"}); e == nil {
+		t.Error("#1 Failed")
+	}
+	if h, e := con.AddSignalHandler(&MatchRule{Type: "signal"}, func(msg *Message) {}); h != nil || e == nil {
+		t.Error("#2 Failed")
+	}
+	if 0 != con.signalMatchRules.Len() || 0 != len(con._MatchRules()) {
+		t.Error("#3 Failed")
+	}
+}
+
+func TestSubscribeInvalidArg(t *testing.T) {
+	con, bus := newTestConnection()
+
+	if _, _, e := con.Subscribe(&MatchRule{Type: "signal", Args: map[int]string{64: "x"}}); e == nil {
+		t.Error("#1 Failed")
+	}
+	if _, e := con.AddSignalHandler(&MatchRule{ArgPaths: map[int]string{-1: "/"}}, func(msg *Message) {}); e == nil {
+		t.Error("#2 Failed")
+	}
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if 0 != bus.matches.Len() {
+		t.Error("#3 Failed:", bus.matches.Data())
+	}
+}
+
+func TestSubscribePendingRejected(t *testing.T) {
+	con, bus := newTestConnection()
+
+	// the first AddMatch is held back and then refused
+	started := make(chan bool)
+	release := make(chan bool)
+	first := true
+	bus.onCall = func(call *Message) {
+		if "AddMatch" != call.Member {
+			return
+		}
+		if first {
+			first = false
+			bus.refused = "AddMatch"
+			started <- true
+			<-release
+		} else {
+			bus.refused = ""
+		}
+	}
+
+	mr := &MatchRule{Type: "signal", Member: "Tick"}
+	errs := make(chan os.Error, 2)
+	go func() {
+		_, _, e := con.Subscribe(mr)
+		errs <- e
+	}()
+	<-started
+	go func() {
+		_, _, e := con.Subscribe(mr)
+		errs <- e
+	}()
+	time.Sleep(5e7) // the second subscriber finds the rule in flight
+	release <- true
+
+	// both share the rejection, and nothing is left behind
+	if e := <-errs; e == nil {
+		t.Error("#1 Failed")
+	}
+	if e := <-errs; e == nil {
+		t.Error("#2 Failed")
+	}
+	if 0 != con.signalMatchRules.Len() || 0 != len(con._MatchRules()) {
+		t.Error("#3 Failed")
+	}
+	bus.mutex.Lock()
+	if 0 != bus.matches.Len() {
+		t.Error("#4 Failed:", bus.matches.Data())
+	}
+	bus.mutex.Unlock()
+}
+
+func TestSubscribeOverflow(t *testing.T) {
+	con, bus := newTestConnection()
+
+	ch, _, _ := con.Subscribe(&MatchRule{Type: "signal"})
+	for i := 0; i < SignalBufferSize+5; i++ {
+		bus._EmitSignal("Tick")
+	}
+	// the reply is dispatched after every signal sent before it
+	con.CallMethod(con.proxy, "ListNames")
+
+	if 5 != con.DroppedSignals() {
+		t.Error("#1 Failed:", con.DroppedSignals())
+	}
+	if SignalBufferSize != len(ch) {
+		t.Error("#2 Failed:", len(ch))
+	}
+}
+
+func TestSignalClosedOnClose(t *testing.T) {
+	con, bus := newTestConnection()
+
+	ch := make(chan *Message, 1)
+	con.Signal(ch)
+	bus._EmitSignal("Tick")
+	if msg := <-ch; "Tick" != msg.Member {
+		t.Error("#1 Failed:", msg.Member)
+	}
+
+	con.Close()
+	if msg := <-ch; msg != nil || !closed(ch) {
+		t.Error("#2 Failed")
+	}
+}
+
+func TestRemoveSignalHandler(t *testing.T) {
+	con, bus := newTestConnection()
+
+	mr := &MatchRule{Type: "signal", Interface: "org.test"}
+	h1, _ := con.AddSignalHandler(mr, func(msg *Message) {})
+	h2, _ := con.AddSignalHandler(&MatchRule{Type: "signal", Interface: "org.test"}, func(msg *Message) {})
+	bus.mutex.Lock()
+	if 1 != bus.matches.Len() {
+		t.Error("#1 Failed:", bus.matches.Len())
+	}
+	bus.mutex.Unlock()
+
+	if e := h1.Remove(); e != nil {
+		t.Error("#2 Failed:", e)
+	}
+	bus.mutex.Lock()
+	if 0 != bus.removed.Len() {
+		t.Error("#3 Failed")
+	}
+	bus.mutex.Unlock()
+
+	if e := con.RemoveSignalHandler(h2); e != nil {
+		t.Error("#4 Failed:", e)
+	}
+	bus.mutex.Lock()
+	if 1 != bus.removed.Len() || mr._ToString() != bus.removed.At(0) {
+		t.Error("#5 Failed:", bus.removed.Data())
+	}
+	bus.mutex.Unlock()
+	if 0 != con.signalMatchRules.Len() {
+		t.Error("#6 Failed")
+	}
+
+	if e := h2.Remove(); e == nil {
+		t.Error("#7 Failed")
+	}
+}
+
+func TestAddMessageHandler(t *testing.T) {
+	con, _ := newTestConnection()
+	con._SetUniqName(":1.5")
+
+	received := make(chan *Message, 4)
+	con.AddMessageHandler(&MatchRule{Type: "method_return", Eavesdrop: true}, func(msg *Message) { received <- msg })
+	signals, _, _ := con.Subscribe(&MatchRule{})
+
+	replied := make(chan *Message, 1)
+	con.replyMutex.Lock()
+	con.methodCallReplies[1000] = func(msg *Message) { replied <- msg }
+	con.replyMutex.Unlock()
+
+	// a reply to another connection that reuses our pending serial
+	eavesdropped := NewMessage()
+	eavesdropped.Type = METHOD_RETURN
+	eavesdropped.Dest = ":1.9"
+	eavesdropped.replySerial = 1000
+	con._MessageDispatch(eavesdropped)
+
+	if msg := <-received; msg != eavesdropped {
+		t.Error("#1 Failed")
+	}
+	if 0 != len(replied) || 0 != len(signals) {
+		t.Error("#2 Failed")
+	}
+
+	ours := NewMessage()
+	ours.Type = METHOD_RETURN
+	ours.Dest = ":1.5"
+	ours.replySerial = 1000
+	con._MessageDispatch(ours)
+
+	if msg := <-replied; msg != ours {
+		t.Error("#3 Failed")
+	}
+	if msg := <-received; msg != ours {
+		t.Error("#4 Failed")
+	}
+}
+
+func newSenderSignal(sender string, dest string) *Message {
+	msg := NewMessage()
+	msg.Type = SIGNAL
+	msg.Sender = sender
+	msg.Dest = dest
+	msg.Path = "/org/test"
+	msg.Iface = "org.test"
+	msg.Member = "Tick"
+	return msg
+}
+
+func TestSignalSender(t *testing.T) {
+	con, bus := newTestConnection()
+	bus.owners = map[string]string{"org.foo": ":1.7"}
+
+	ch, _, _ := con.Subscribe(&MatchRule{Type: "signal", Sender: "org.foo", Member: "Tick"})
+
+	bus._Send(newSenderSignal(":1.8", ""))
+	bus._Send(newSenderSignal(":1.7", ""))
+	if msg := <-ch; ":1.7" != msg.Sender {
+		t.Error("#1 Failed:", msg.Sender)
+	}
+
+	// once the name changes hands only the new owner matches
+	bus._EmitNameOwnerChanged("org.foo", ":1.7", ":1.8")
+	bus._Send(newSenderSignal(":1.7", ""))
+	bus._Send(newSenderSignal(":1.8", ""))
+	if msg := <-ch; ":1.8" != msg.Sender {
+		t.Error("#2 Failed:", msg.Sender)
+	}
+}
+
+func TestSignalDestination(t *testing.T) {
+	con, bus := newTestConnection()
+	con._SetUniqName(":1.5")
+
+	ch, _, _ := con.Subscribe(&MatchRule{Type: "signal", Member: "Tick"})
+	all, _, _ := con.Subscribe(&MatchRule{Type: "signal", Member: "Tick", Eavesdrop: true})
+
+	bus._Send(newSenderSignal(":1.7", ":1.9"))
+	bus._Send(newSenderSignal(":1.7", ":1.5"))
+	if msg := <-ch; ":1.5" != msg.Dest {
+		t.Error("#1 Failed:", msg.Dest)
+	}
+	if msg := <-all; ":1.9" != msg.Dest {
+		t.Error("#2 Failed:", msg.Dest)
+	}
+}
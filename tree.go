@@ -0,0 +1,140 @@
+package dbus
+
+import (
+	"container/vector"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const defaultTreeConcurrency = 8
+
+// TreeOptions limits the walk done by GetObjectTree.
+type TreeOptions struct {
+	MaxDepth      int // levels below the root to follow; 0 follows all
+	MaxConcurrent int // Introspect calls in flight; defaults to 8
+}
+
+// An ObjectTree is an object found by GetObjectTree and the objects below
+// it, as `busctl tree` shows them.
+type ObjectTree struct {
+	Path     string
+	Object   *Object
+	Children []*ObjectTree // sorted by path
+}
+
+type treeWalk struct {
+	conn     *Connection
+	dest     string
+	maxDepth int
+	calls    chan bool  // one token per Introspect call in flight
+	mutex    sync.Mutex // guards visited
+	visited  map[string]bool
+}
+
+// GetObjectTree introspects path on dest and, recursively, the child
+// nodes each object lists. Every path is visited once, and child names
+// that would lead outside the object they were listed by are ignored, so
+// a misbehaving service cannot make the walk loop. An object that fails
+// to introspect is kept as a leaf with no introspection data; only a
+// failure at path itself is returned. opts may be nil.
+func (p *Connection) GetObjectTree(dest string, path string, opts *TreeOptions) (*ObjectTree, os.Error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, os.NewError("Invalid object path")
+	}
+
+	walk := &treeWalk{conn: p, dest: dest}
+	concurrent := defaultTreeConcurrency
+	if opts != nil {
+		walk.maxDepth = opts.MaxDepth
+		if 0 < opts.MaxConcurrent {
+			concurrent = opts.MaxConcurrent
+		}
+	}
+	walk.calls = make(chan bool, concurrent)
+	walk.visited = map[string]bool{path: true}
+
+	return walk._Walk(path, 0)
+}
+
+func (p *treeWalk) _Walk(path string, depth int) (*ObjectTree, os.Error) {
+	p.calls <- true
+	intro, e := p.conn._Introspect(nil, 0, p.dest, path)
+	<-p.calls
+
+	obj := &Object{conn: p.conn, dest: p.dest, path: path, intro: intro}
+	node := &ObjectTree{Path: path, Object: obj, Children: []*ObjectTree{}}
+	if e != nil || (0 < p.maxDepth && p.maxDepth <= depth) {
+		return node, e
+	}
+
+	paths := new(vector.StringVector)
+	for _, child := range intro.GetNodes() {
+		if childPath := _ChildPath(path, child.GetName()); childPath != "" && p._Visit(childPath) {
+			paths.Push(childPath)
+		}
+	}
+	children := paths.Data()
+	sort.SortStrings(children)
+
+	node.Children = make([]*ObjectTree, len(children))
+	done := make(chan bool)
+	for i, childPath := range children {
+		go func(i int, childPath string) {
+			node.Children[i], _ = p._Walk(childPath, depth+1)
+			done <- true
+		}(i, childPath)
+	}
+	for i := 0; i < len(children); i++ {
+		<-done
+	}
+	return node, nil
+}
+
+// _Visit reports whether path is seen for the first time.
+func (p *treeWalk) _Visit(path string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.visited[path] {
+		return false
+	}
+	p.visited[path] = true
+	return true
+}
+
+// _ChildPath returns the path of the node called name in the
+// introspection data of parent, or "" if it is not below parent. Names
+// are normally a single path element; old services give absolute paths.
+func _ChildPath(parent string, name string) string {
+	if strings.HasPrefix(name, "/") {
+		if _ChildNode(parent, name) == "" || strings.HasSuffix(name, "/") || 0 <= strings.Index(name, "//") {
+			return ""
+		}
+		return name
+	}
+
+	if name == "" {
+		return ""
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_') {
+			return ""
+		}
+	}
+	if "/" == parent {
+		return parent + name
+	}
+	return parent + "/" + name
+}
+
+// Walk calls proc for the tree and every object below it, parents before
+// their children.
+func (p *ObjectTree) Walk(proc func(*ObjectTree)) {
+	proc(p)
+	for _, child := range p.Children {
+		child.Walk(proc)
+	}
+}
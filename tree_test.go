@@ -0,0 +1,101 @@
+package dbus
+
+import (
+	"testing"
+)
+
+func TestGetObjectTree(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	server.Export(new(testObject), "/org/test/a", "org.test")
+	server.Export(new(testObject), "/org/test/b/c", "org.test")
+	server.Export(new(testObject), "/org/test/b/d", "org.test")
+
+	tree, e := client.GetObjectTree("", "/", &TreeOptions{MaxConcurrent: 2})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	paths := []string{}
+	tree.Walk(func(node *ObjectTree) {
+		p := make([]string, len(paths)+1)
+		for i, v := range paths {
+			p[i] = v
+		}
+		p[len(paths)] = node.Path
+		paths = p
+	})
+	expected := []string{"/", "/org", "/org/test", "/org/test/a", "/org/test/b", "/org/test/b/c", "/org/test/b/d"}
+	if len(paths) != len(expected) {
+		t.Fatalf("#1 Failed: %v", paths)
+	}
+	for i, v := range expected {
+		if paths[i] != v {
+			t.Errorf("#2 Failed: %v", paths)
+			break
+		}
+	}
+
+	leaf := tree.Children[0].Children[0].Children[0].Object
+	if leaf.GetPath() != "/org/test/a" || leaf.GetIntrospect().GetInterfaceData("org.test") == nil {
+		t.Error("#3 Failed")
+	}
+}
+
+func TestGetObjectTreeDepth(t *testing.T) {
+	server, client := newPeerConnections()
+	defer server.Close()
+	defer client.Close()
+
+	server.Export(new(testObject), "/org/test/a", "org.test")
+
+	tree, e := client.GetObjectTree("", "/org", &TreeOptions{MaxDepth: 1})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(tree.Children) != 1 || tree.Children[0].Path != "/org/test" {
+		t.Fatalf("#1 Failed: %v", tree.Children)
+	}
+	// the last level is introspected but not followed
+	test := tree.Children[0]
+	if test.Object.GetIntrospect() == nil || len(test.Children) != 0 {
+		t.Error("#2 Failed")
+	}
+}
+
+func TestGetObjectTreeError(t *testing.T) {
+	con, _ := newTestConnection()
+	defer con.Close()
+
+	// the test bus does not answer Introspect with a document
+	if _, e := con.GetObjectTree("org.test", "/", nil); e == nil {
+		t.Error("#1 Failed")
+	}
+	if _, e := con.GetObjectTree("org.test", "relative", nil); e == nil {
+		t.Error("#2 Failed")
+	}
+}
+
+func TestChildPath(t *testing.T) {
+	tests := []struct {
+		parent, name, path string
+	}{
+		{"/", "org", "/org"},
+		{"/org", "test", "/org/test"},
+		{"/org", "/org/test", "/org/test"},
+		{"/org", "..", ""},
+		{"/org", "a/b", ""},
+		{"/org", "", ""},
+		{"/org", "/", ""},
+		{"/org", "/org", ""},
+		{"/org", "/other", ""},
+		{"/org", "/org//x", ""},
+	}
+	for _, test := range tests {
+		if path := _ChildPath(test.parent, test.name); path != test.path {
+			t.Errorf("%s %s: %s", test.parent, test.name, path)
+		}
+	}
+}